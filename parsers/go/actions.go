@@ -0,0 +1,337 @@
+package dsl
+
+import (
+	"encoding/json"
+)
+
+// Action type names, as used in the "action" field of the JSON encoding
+const (
+	ActionCreateTrack      = "create_track"
+	ActionCreateClipAtBar  = "create_clip_at_bar"
+	ActionCreateClip       = "create_clip"
+	ActionAddMidi          = "add_midi"
+	ActionAddTrackFX       = "add_track_fx"
+	ActionAddInstrument    = "add_instrument"
+	ActionSetTrackVolume   = "set_track_volume"
+	ActionSetTrackPan      = "set_track_pan"
+	ActionSetTrackMute     = "set_track_mute"
+	ActionSetTrackSolo     = "set_track_solo"
+	ActionSetTrackName     = "set_track_name"
+	ActionSetTrackSelected = "set_track_selected"
+	ActionDeleteTrack      = "delete_track"
+	ActionDeleteClip       = "delete_clip"
+)
+
+// actionPrototypes holds the zero value of every action type, in documentation order
+var actionPrototypes = []Action{
+	CreateTrack{},
+	CreateClipAtBar{},
+	CreateClip{},
+	AddMidi{},
+	AddTrackFX{},
+	AddInstrument{},
+	SetTrackVolume{},
+	SetTrackPan{},
+	SetTrackMute{},
+	SetTrackSolo{},
+	SetTrackName{},
+	SetTrackSelected{},
+	DeleteTrack{},
+	DeleteClip{},
+}
+
+// Action is a DAW operation produced by the parser
+// Every action encodes to JSON as an object with an "action" field naming its type,
+// identical to the maps returned by ParseDSL.
+type Action interface {
+	// Type returns the action type name, e.g. "create_track"
+	Type() string
+	// Map returns the action as a map, in the form returned by ParseDSL
+	Map() map[string]interface{}
+}
+
+// trackAction is an action on an existing track, which can reference it by handle
+type trackAction interface {
+	Action
+	withTrackRef(ref string) Action
+}
+
+// CreateTrack creates a new track at Index
+type CreateTrack struct {
+	Instrument string `json:"instrument,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Index      int    `json:"index" schema:"minimum=0"`
+	Ref        string `json:"ref,omitempty"` // Handle later actions use as their track_ref; see SetTrackHandles
+}
+
+// CreateClipAtBar creates a clip on Track starting at a 1-based bar
+type CreateClipAtBar struct {
+	Track      int    `json:"track" schema:"minimum=0"`
+	TrackRef   string `json:"track_ref,omitempty"`
+	Bar        int    `json:"bar" schema:"minimum=1"`
+	LengthBars int    `json:"length_bars" schema:"minimum=1"`
+}
+
+// CreateClip creates a clip on Track at a time position
+type CreateClip struct {
+	Track    int     `json:"track" schema:"minimum=0"`
+	TrackRef string  `json:"track_ref,omitempty"`
+	Position float64 `json:"position" schema:"minimum=0"`
+	Length   float64 `json:"length" schema:"minimum=0"`
+}
+
+// AddMidi adds MIDI notes to the clip on Track
+type AddMidi struct {
+	Track    int        `json:"track" schema:"minimum=0"`
+	TrackRef string     `json:"track_ref,omitempty"`
+	Notes    []MidiNote `json:"notes"`
+}
+
+// AddTrackFX adds an effect plugin to Track
+type AddTrackFX struct {
+	Track    int    `json:"track" schema:"minimum=0"`
+	TrackRef string `json:"track_ref,omitempty"`
+	FXName   string `json:"fxname"`
+}
+
+// AddInstrument adds an instrument plugin to Track
+type AddInstrument struct {
+	Track    int    `json:"track" schema:"minimum=0"`
+	TrackRef string `json:"track_ref,omitempty"`
+	FXName   string `json:"fxname"`
+}
+
+// SetTrackVolume sets the volume of Track in dB
+type SetTrackVolume struct {
+	Track    int     `json:"track" schema:"minimum=0"`
+	TrackRef string  `json:"track_ref,omitempty"`
+	VolumeDB float64 `json:"volume_db" schema:"minimum=-150,maximum=24"`
+}
+
+// SetTrackPan sets the pan of Track, from -1 (left) to 1 (right)
+type SetTrackPan struct {
+	Track    int     `json:"track" schema:"minimum=0"`
+	TrackRef string  `json:"track_ref,omitempty"`
+	Pan      float64 `json:"pan" schema:"minimum=-1,maximum=1"`
+}
+
+// SetTrackMute mutes or unmutes Track
+type SetTrackMute struct {
+	Track    int    `json:"track" schema:"minimum=0"`
+	TrackRef string `json:"track_ref,omitempty"`
+	Mute     bool   `json:"mute"`
+}
+
+// SetTrackSolo solos or unsolos Track
+type SetTrackSolo struct {
+	Track    int    `json:"track" schema:"minimum=0"`
+	TrackRef string `json:"track_ref,omitempty"`
+	Solo     bool   `json:"solo"`
+}
+
+// SetTrackName renames Track
+type SetTrackName struct {
+	Track    int    `json:"track" schema:"minimum=0"`
+	TrackRef string `json:"track_ref,omitempty"`
+	Name     string `json:"name"`
+}
+
+// SetTrackSelected selects or deselects Track
+type SetTrackSelected struct {
+	Track    int    `json:"track" schema:"minimum=0"`
+	TrackRef string `json:"track_ref,omitempty"`
+	Selected bool   `json:"selected"`
+}
+
+// DeleteTrack deletes Track
+type DeleteTrack struct {
+	Track    int    `json:"track" schema:"minimum=0"`
+	TrackRef string `json:"track_ref,omitempty"`
+}
+
+// DeleteClip deletes a clip on Track identified by exactly one of Clip, Bar or Position
+type DeleteClip struct {
+	Track    int      `json:"track" schema:"minimum=0"`
+	TrackRef string   `json:"track_ref,omitempty"`
+	Clip     *int     `json:"clip,omitempty" schema:"minimum=0,oneof"`
+	Bar      *int     `json:"bar,omitempty" schema:"minimum=1,oneof"`
+	Position *float64 `json:"position,omitempty" schema:"minimum=0,oneof"`
+}
+
+// Type implementations
+
+func (CreateTrack) Type() string      { return ActionCreateTrack }
+func (CreateClipAtBar) Type() string  { return ActionCreateClipAtBar }
+func (CreateClip) Type() string       { return ActionCreateClip }
+func (AddMidi) Type() string          { return ActionAddMidi }
+func (AddTrackFX) Type() string       { return ActionAddTrackFX }
+func (AddInstrument) Type() string    { return ActionAddInstrument }
+func (SetTrackVolume) Type() string   { return ActionSetTrackVolume }
+func (SetTrackPan) Type() string      { return ActionSetTrackPan }
+func (SetTrackMute) Type() string     { return ActionSetTrackMute }
+func (SetTrackSolo) Type() string     { return ActionSetTrackSolo }
+func (SetTrackName) Type() string     { return ActionSetTrackName }
+func (SetTrackSelected) Type() string { return ActionSetTrackSelected }
+func (DeleteTrack) Type() string      { return ActionDeleteTrack }
+func (DeleteClip) Type() string       { return ActionDeleteClip }
+
+// Map implementations
+
+func (a CreateTrack) Map() map[string]interface{} {
+	m := map[string]interface{}{"action": a.Type(), "index": a.Index}
+	if a.Instrument != "" {
+		m["instrument"] = a.Instrument
+	}
+	if a.Name != "" {
+		m["name"] = a.Name
+	}
+	if a.Ref != "" {
+		m["ref"] = a.Ref
+	}
+	return m
+}
+
+// trackActionMap starts the map of an action on a track, with track_ref if the track has a handle
+func trackActionMap(action string, track int, ref string) map[string]interface{} {
+	m := map[string]interface{}{"action": action, "track": track}
+	if ref != "" {
+		m["track_ref"] = ref
+	}
+	return m
+}
+
+func (a CreateClipAtBar) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["bar"] = a.Bar
+	m["length_bars"] = a.LengthBars
+	return m
+}
+
+func (a CreateClip) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["position"] = a.Position
+	m["length"] = a.Length
+	return m
+}
+
+func (a AddMidi) Map() map[string]interface{} {
+	notes := a.Notes
+	if notes == nil {
+		notes = []MidiNote{}
+	}
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["notes"] = notes
+	return m
+}
+
+func (a AddTrackFX) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["fxname"] = a.FXName
+	return m
+}
+
+func (a AddInstrument) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["fxname"] = a.FXName
+	return m
+}
+
+func (a SetTrackVolume) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["volume_db"] = a.VolumeDB
+	return m
+}
+
+func (a SetTrackPan) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["pan"] = a.Pan
+	return m
+}
+
+func (a SetTrackMute) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["mute"] = a.Mute
+	return m
+}
+
+func (a SetTrackSolo) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["solo"] = a.Solo
+	return m
+}
+
+func (a SetTrackName) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["name"] = a.Name
+	return m
+}
+
+func (a SetTrackSelected) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	m["selected"] = a.Selected
+	return m
+}
+
+func (a DeleteTrack) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	return m
+}
+
+func (a DeleteClip) Map() map[string]interface{} {
+	m := trackActionMap(a.Type(), a.Track, a.TrackRef)
+	if a.Clip != nil {
+		m["clip"] = *a.Clip
+	}
+	if a.Bar != nil {
+		m["bar"] = *a.Bar
+	}
+	if a.Position != nil {
+		m["position"] = *a.Position
+	}
+	return m
+}
+
+// Track handle implementations
+
+func (a CreateClipAtBar) withTrackRef(ref string) Action  { a.TrackRef = ref; return a }
+func (a CreateClip) withTrackRef(ref string) Action       { a.TrackRef = ref; return a }
+func (a AddMidi) withTrackRef(ref string) Action          { a.TrackRef = ref; return a }
+func (a AddTrackFX) withTrackRef(ref string) Action       { a.TrackRef = ref; return a }
+func (a AddInstrument) withTrackRef(ref string) Action    { a.TrackRef = ref; return a }
+func (a SetTrackVolume) withTrackRef(ref string) Action   { a.TrackRef = ref; return a }
+func (a SetTrackPan) withTrackRef(ref string) Action      { a.TrackRef = ref; return a }
+func (a SetTrackMute) withTrackRef(ref string) Action     { a.TrackRef = ref; return a }
+func (a SetTrackSolo) withTrackRef(ref string) Action     { a.TrackRef = ref; return a }
+func (a SetTrackName) withTrackRef(ref string) Action     { a.TrackRef = ref; return a }
+func (a SetTrackSelected) withTrackRef(ref string) Action { a.TrackRef = ref; return a }
+func (a DeleteTrack) withTrackRef(ref string) Action      { a.TrackRef = ref; return a }
+func (a DeleteClip) withTrackRef(ref string) Action       { a.TrackRef = ref; return a }
+
+// JSON encoding goes through Map so typed actions and ParseDSL maps encode identically
+
+func (a CreateTrack) MarshalJSON() ([]byte, error)      { return json.Marshal(a.Map()) }
+func (a CreateClipAtBar) MarshalJSON() ([]byte, error)  { return json.Marshal(a.Map()) }
+func (a CreateClip) MarshalJSON() ([]byte, error)       { return json.Marshal(a.Map()) }
+func (a AddMidi) MarshalJSON() ([]byte, error)          { return json.Marshal(a.Map()) }
+func (a AddTrackFX) MarshalJSON() ([]byte, error)       { return json.Marshal(a.Map()) }
+func (a AddInstrument) MarshalJSON() ([]byte, error)    { return json.Marshal(a.Map()) }
+func (a SetTrackVolume) MarshalJSON() ([]byte, error)   { return json.Marshal(a.Map()) }
+func (a SetTrackPan) MarshalJSON() ([]byte, error)      { return json.Marshal(a.Map()) }
+func (a SetTrackMute) MarshalJSON() ([]byte, error)     { return json.Marshal(a.Map()) }
+func (a SetTrackSolo) MarshalJSON() ([]byte, error)     { return json.Marshal(a.Map()) }
+func (a SetTrackName) MarshalJSON() ([]byte, error)     { return json.Marshal(a.Map()) }
+func (a SetTrackSelected) MarshalJSON() ([]byte, error) { return json.Marshal(a.Map()) }
+func (a DeleteTrack) MarshalJSON() ([]byte, error)      { return json.Marshal(a.Map()) }
+func (a DeleteClip) MarshalJSON() ([]byte, error)       { return json.Marshal(a.Map()) }
+
+// ActionMaps converts typed actions to the map form returned by ParseDSL
+func ActionMaps(actions []Action) []map[string]interface{} {
+	if actions == nil {
+		return nil
+	}
+	maps := make([]map[string]interface{}, len(actions))
+	for i, action := range actions {
+		maps[i] = action.Map()
+	}
+	return maps
+}
@@ -0,0 +1,114 @@
+package dsl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestActions_jsonMatchesMaps(t *testing.T) {
+	clip, bar, position := 0, 2, 4.5
+	actions := []Action{
+		CreateTrack{Instrument: "Serum", Name: "Bass", Index: 0},
+		CreateTrack{Index: 3},
+		CreateClipAtBar{Track: 0, Bar: 1, LengthBars: 4},
+		CreateClip{Track: 0, Position: 1.5, Length: 2},
+		AddMidi{Track: 0, Notes: []MidiNote{{Pitch: 60, Velocity: 100, Start: 0, Duration: 1}, {Pitch: 36, Velocity: 90, Start: 1, Duration: 0.5, Channel: 9}}},
+		AddMidi{Track: 1},
+		AddTrackFX{Track: 0, FXName: "ReaEQ"},
+		AddInstrument{Track: 0, FXName: "Serum"},
+		SetTrackVolume{Track: 0, VolumeDB: -3},
+		SetTrackPan{Track: 0, Pan: -0.5},
+		SetTrackMute{Track: 0, Mute: true},
+		SetTrackSolo{Track: 0, Solo: false},
+		SetTrackName{Track: 0, Name: "Lead"},
+		SetTrackSelected{Track: 0, Selected: true},
+		DeleteTrack{Track: 2},
+		DeleteClip{Track: 0, Clip: &clip},
+		DeleteClip{Track: 0, Bar: &bar},
+		DeleteClip{Track: 0, Position: &position},
+	}
+
+	for _, action := range actions {
+		t.Run(action.Type(), func(t *testing.T) {
+			typed, err := json.Marshal(action)
+			if err != nil {
+				t.Fatalf("json.Marshal(action) error = %v", err)
+			}
+			untyped, err := json.Marshal(action.Map())
+			if err != nil {
+				t.Fatalf("json.Marshal(map) error = %v", err)
+			}
+			if string(typed) != string(untyped) {
+				t.Errorf("json.Marshal() = %s, want %s", typed, untyped)
+			}
+			if action.Map()["action"] != action.Type() {
+				t.Errorf("Map()[action] = %v, want %s", action.Map()["action"], action.Type())
+			}
+		})
+	}
+}
+
+func TestActions_json(t *testing.T) {
+	bar := 2
+	tests := []struct {
+		action Action
+		want   string
+	}{
+		{
+			action: CreateTrack{Instrument: "Serum", Index: 0},
+			want:   `{"action":"create_track","index":0,"instrument":"Serum"}`,
+		},
+		{
+			action: CreateClipAtBar{Track: 1, Bar: 3, LengthBars: 4},
+			want:   `{"action":"create_clip_at_bar","bar":3,"length_bars":4,"track":1}`,
+		},
+		{
+			action: AddMidi{Track: 0},
+			want:   `{"action":"add_midi","notes":[],"track":0}`,
+		},
+		{
+			action: DeleteClip{Track: 0, Bar: &bar},
+			want:   `{"action":"delete_clip","bar":2,"track":0}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.action)
+		if err != nil {
+			t.Fatalf("json.Marshal() error = %v", err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("json.Marshal(%T) = %s, want %s", tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestParser_ParseProgram(t *testing.T) {
+	dslCode := `track(instrument="Serum", name="Bass").newClip(bar=1, length_bars=8).addFX(fxname="ReaEQ").setVolume(volume_db=-3)
+track(id=1).newClip(start=2, length=1.5).setMute(mute=true)`
+
+	got, err := NewParser().ParseProgram(dslCode)
+	if err != nil {
+		t.Fatalf("ParseProgram() error = %v", err)
+	}
+	want := []Action{
+		CreateTrack{Instrument: "Serum", Name: "Bass", Index: 0},
+		CreateClipAtBar{Track: 0, Bar: 1, LengthBars: 8},
+		AddTrackFX{Track: 0, FXName: "ReaEQ"},
+		SetTrackVolume{Track: 0, VolumeDB: -3},
+		CreateClip{Track: 0, Position: 2, Length: 1.5},
+		SetTrackMute{Track: 0, Mute: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseProgram() = %#v, want %#v", got, want)
+	}
+
+	maps, err := NewParser().ParseDSL(dslCode)
+	if err != nil {
+		t.Fatalf("ParseDSL() error = %v", err)
+	}
+	if !reflect.DeepEqual(maps, ActionMaps(got)) {
+		t.Errorf("ParseDSL() = %v, want ActionMaps(ParseProgram()) = %v", maps, ActionMaps(got))
+	}
+}
@@ -0,0 +1,224 @@
+package dsl
+
+import (
+	"fmt"
+)
+
+// ApplyError is an action that cannot be applied to a project state
+type ApplyError struct {
+	Index  int    // Position of the failing action in the list
+	Action string // Action type, e.g. "set_track_volume"
+	Msg    string
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("apply action %d (%s): %s", e.Index, e.Action, e.Msg)
+}
+
+// Apply executes actions against a copy of state and returns the resulting state
+// Actions run in order, as the DAW would run them: create_track inserts at its index and
+// delete_track removes the track, shifting the tracks after it. An action with a track_ref
+// runs on the track created with that ref, wherever earlier actions moved it, instead of on
+// its track index, while a track index always means the track at that index now. Impossible
+// operations stop Apply with an *ApplyError: a track index or handle that does not exist, a
+// handle whose track an earlier action deleted, a clip that is not there,
+// notes with no clip to go into, or values the DSL cannot express. add_midi adds its notes to
+// the most recently created clip of the track. The input state is not modified; a nil state is
+// an empty project.
+//
+// Example:
+//
+//	next, err := Apply(&ProjectState{}, []Action{CreateTrack{Name: "Bass"}, SetTrackMute{Track: 0, Mute: true}})
+//	// next.Tracks == []TrackState{{Name: "Bass", Mute: true}}
+func Apply(state *ProjectState, actions []Action) (*ProjectState, error) {
+	next := state.Copy()
+	deletedRefs := make(map[string]int) // Deleted track handle -> index of the deleting action
+	lastDelete, lastDeleted := -1, -1   // Most recent delete_track action and the track it deleted
+
+	for i, action := range actions {
+		if err := validateAction(action); err != nil {
+			return nil, applyError(i, action, err.Error())
+		}
+		track := actionTrackIndex(action)
+		if ref := actionTrackRef(action); ref != "" {
+			track = next.TrackByRef(ref)
+			if track < 0 {
+				if by, ok := deletedRefs[ref]; ok {
+					return nil, applyError(i, action, fmt.Sprintf("track %q was deleted by action %d", ref, by))
+				}
+				return nil, applyError(i, action, fmt.Sprintf("unknown track handle %q", ref))
+			}
+		} else if track >= 0 && !next.HasTrack(track) && lastDelete >= 0 {
+			// Indices name tracks by position, so say which delete left too few tracks
+			return nil, applyError(i, action, fmt.Sprintf("track %d does not exist, the project has %d tracks after action %d deleted track %d",
+				track+1, len(next.Tracks), lastDelete, lastDeleted+1))
+		}
+		if create, ok := action.(CreateTrack); ok && next.TrackByRef(create.Ref) >= 0 {
+			return nil, applyError(i, action, fmt.Sprintf("track handle %q is already in use", create.Ref))
+		}
+		if _, ok := action.(DeleteTrack); ok && next.HasTrack(track) && next.Tracks[track].Ref != "" {
+			deletedRefs[next.Tracks[track].Ref] = i
+		}
+		if err := next.apply(action, track); err != nil {
+			return nil, applyError(i, action, err.Error())
+		}
+
+		switch a := action.(type) {
+		case CreateTrack:
+			delete(deletedRefs, a.Ref)
+		case DeleteTrack:
+			lastDelete, lastDeleted = i, track
+		}
+	}
+	return next, nil
+}
+
+func applyError(index int, action Action, msg string) *ApplyError {
+	return &ApplyError{Index: index, Action: action.Type(), Msg: msg}
+}
+
+// validateAction checks that an action's values are ones the DSL can express
+func validateAction(action Action) error {
+	var err error
+	if create, ok := action.(CreateTrack); ok {
+		_, err = renderTrackCall(create)
+	} else {
+		_, _, err = renderMethodCall(action)
+	}
+	if perr, ok := err.(*ParseError); ok {
+		return fmt.Errorf("%s", perr.Msg)
+	}
+	return err
+}
+
+// actionTrackIndex returns the track an action applies to, or -1 for create_track
+func actionTrackIndex(action Action) int {
+	if _, ok := action.(CreateTrack); ok {
+		return -1
+	}
+	track, _ := action.Map()["track"].(int)
+	return track
+}
+
+// actionTrackRef returns the handle of the track an action applies to, or "" if it has none
+func actionTrackRef(action Action) string {
+	ref, _ := action.Map()["track_ref"].(string)
+	return ref
+}
+
+// Copy returns a deep copy of the state; a nil state copies to an empty project
+func (s *ProjectState) Copy() *ProjectState {
+	if s == nil {
+		return &ProjectState{Tracks: []TrackState{}}
+	}
+	next := &ProjectState{Tempo: s.Tempo, Tracks: make([]TrackState, len(s.Tracks))}
+	for i, track := range s.Tracks {
+		if track.FX != nil {
+			track.FX = append([]string{}, track.FX...)
+		}
+		if track.Clips != nil {
+			clips := make([]ClipState, len(track.Clips))
+			for j, clip := range track.Clips {
+				if clip.Notes != nil {
+					clip.Notes = append([]MidiNote{}, clip.Notes...)
+				}
+				clips[j] = clip
+			}
+			track.Clips = clips
+		}
+		next.Tracks[i] = track
+	}
+	return next
+}
+
+// apply applies one action in place to the track at index; create_track uses its own index
+//
+//nolint:gocyclo // One case per action type
+func (s *ProjectState) apply(action Action, index int) error {
+	if create, ok := action.(CreateTrack); ok {
+		if create.Index > len(s.Tracks) {
+			return fmt.Errorf("index %d out of range, the project has %d tracks", create.Index, len(s.Tracks))
+		}
+		s.Tracks = append(s.Tracks, TrackState{})
+		copy(s.Tracks[create.Index+1:], s.Tracks[create.Index:])
+		s.Tracks[create.Index] = TrackState{Name: create.Name, Instrument: create.Instrument, Ref: create.Ref}
+		return nil
+	}
+
+	if !s.HasTrack(index) {
+		return fmt.Errorf("track %d does not exist, the project has %d tracks", index+1, len(s.Tracks))
+	}
+	track := &s.Tracks[index]
+
+	switch a := action.(type) {
+	case CreateClipAtBar:
+		track.Clips = append(track.Clips, ClipState{Bar: a.Bar, LengthBars: a.LengthBars})
+	case CreateClip:
+		track.Clips = append(track.Clips, ClipState{Position: a.Position, Length: a.Length})
+	case AddMidi:
+		if len(track.Clips) == 0 {
+			return fmt.Errorf("track %d has no clip to add notes to", index+1)
+		}
+		clip := &track.Clips[len(track.Clips)-1]
+		clip.Notes = append(clip.Notes, a.Notes...)
+	case AddTrackFX:
+		track.FX = append(track.FX, a.FXName)
+	case AddInstrument:
+		track.Instrument = a.FXName
+	case SetTrackVolume:
+		track.VolumeDB = a.VolumeDB
+	case SetTrackPan:
+		track.Pan = a.Pan
+	case SetTrackMute:
+		track.Mute = a.Mute
+	case SetTrackSolo:
+		track.Solo = a.Solo
+	case SetTrackName:
+		track.Name = a.Name
+	case SetTrackSelected:
+		track.Selected = a.Selected
+	case DeleteTrack:
+		s.Tracks = append(s.Tracks[:index], s.Tracks[index+1:]...)
+	case DeleteClip:
+		i := findClip(track.Clips, a)
+		if i < 0 {
+			return fmt.Errorf("track %d has no clip matching %s", index+1, clipTarget(a))
+		}
+		track.Clips = append(track.Clips[:i], track.Clips[i+1:]...)
+	default:
+		return fmt.Errorf("cannot apply action type %T", action)
+	}
+	return nil
+}
+
+// findClip returns the index of the clip a delete_clip action targets, or -1
+func findClip(clips []ClipState, a DeleteClip) int {
+	if a.Clip != nil {
+		if *a.Clip < len(clips) {
+			return *a.Clip
+		}
+		return -1
+	}
+	for i, clip := range clips {
+		switch {
+		case a.Bar != nil && clip.Bar == *a.Bar:
+			return i
+		case a.Position != nil && clip.Bar == 0 && clip.Position == *a.Position:
+			return i
+		}
+	}
+	return -1
+}
+
+// clipTarget describes the clip a delete_clip action targets, e.g. "bar=5"
+func clipTarget(a DeleteClip) string {
+	switch {
+	case a.Clip != nil:
+		return fmt.Sprintf("clip=%d", *a.Clip)
+	case a.Bar != nil:
+		return fmt.Sprintf("bar=%d", *a.Bar)
+	case a.Position != nil:
+		return fmt.Sprintf("position=%v", *a.Position)
+	}
+	return "nothing"
+}
@@ -0,0 +1,193 @@
+package dsl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "Drums", Selected: true}}}
+	bar, position := 5, 2.0
+	actions := []Action{
+		CreateTrack{Instrument: "Serum", Name: "Bass", Index: 0},
+		CreateClipAtBar{Track: 0, Bar: 1, LengthBars: 4},
+		AddMidi{Track: 0, Notes: []MidiNote{{Pitch: 40, Velocity: 100, Duration: 1}}},
+		CreateClipAtBar{Track: 0, Bar: 5, LengthBars: 4},
+		DeleteClip{Track: 0, Bar: &bar},
+		AddTrackFX{Track: 0, FXName: "ReaEQ"},
+		SetTrackVolume{Track: 0, VolumeDB: -3},
+		SetTrackPan{Track: 0, Pan: -0.5},
+		SetTrackMute{Track: 1, Mute: true},
+		SetTrackSolo{Track: 1, Solo: true},
+		SetTrackName{Track: 1, Name: "Kit"},
+		SetTrackSelected{Track: 1, Selected: false},
+		CreateTrack{Index: 2},
+		AddInstrument{Track: 2, FXName: "Massive"},
+		CreateClip{Track: 2, Position: 2, Length: 1.5},
+		DeleteClip{Track: 2, Position: &position},
+		DeleteTrack{Track: 2},
+	}
+
+	got, err := Apply(state, actions)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []TrackState{
+		{
+			Name:       "Bass",
+			Instrument: "Serum",
+			VolumeDB:   -3,
+			Pan:        -0.5,
+			FX:         []string{"ReaEQ"},
+			Clips: []ClipState{
+				{Bar: 1, LengthBars: 4, Notes: []MidiNote{{Pitch: 40, Velocity: 100, Duration: 1}}},
+			},
+		},
+		{Name: "Kit", Mute: true, Solo: true},
+	}
+	if !reflect.DeepEqual(got.Tracks, want) {
+		t.Errorf("Apply() tracks =\n%+v\nwant\n%+v", got.Tracks, want)
+	}
+
+	// The input state is left alone
+	if len(state.Tracks) != 1 || state.Tracks[0].Name != "Drums" || !state.Tracks[0].Selected {
+		t.Errorf("Apply() modified its input: %+v", state)
+	}
+}
+
+func TestApply_parsedProgram(t *testing.T) {
+	actions, err := NewParser().ParseProgram(`track(instrument="Serum", name="Bass").new_clip(bar=1, length_bars=4).add_midi(notes=[{pitch=36, velocity=100, start=0, duration=1}])
+track(id=1).set_volume(volume_db=-6)`)
+	if err != nil {
+		t.Fatalf("ParseProgram() error = %v", err)
+	}
+	got, err := Apply(nil, actions)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := &ProjectState{Tracks: []TrackState{{
+		Name:       "Bass",
+		Instrument: "Serum",
+		VolumeDB:   -6,
+		Clips:      []ClipState{{Bar: 1, LengthBars: 4, Notes: []MidiNote{{Pitch: 36, Velocity: 100, Duration: 1}}}},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestApply_errors(t *testing.T) {
+	clip := 3
+	tests := []struct {
+		name    string
+		actions []Action
+		index   int
+		want    string
+	}{
+		{"create past the end", []Action{CreateTrack{Index: 2}}, 0, "index 2 out of range, the project has 1 tracks"},
+		{"missing track", []Action{SetTrackMute{Track: 1}}, 0, "track 2 does not exist, the project has 1 tracks"},
+		{"clip on a missing track", []Action{CreateClipAtBar{Track: 4, Bar: 1, LengthBars: 4}}, 0, "track 5 does not exist"},
+		{"notes without a clip", []Action{AddMidi{Track: 0}}, 0, "track 1 has no clip to add notes to"},
+		{"missing clip", []Action{DeleteClip{Track: 0, Clip: &clip}}, 0, "track 1 has no clip matching clip=3"},
+		{"deleted track", []Action{DeleteTrack{Track: 0}, AddTrackFX{Track: 0, FXName: "ReaEQ"}}, 1, "track 1 does not exist, the project has 0 tracks after action 0 deleted track 1"},
+		{"index past the end after deletes", []Action{DeleteTrack{Track: 0}, CreateTrack{Name: "Keys", Index: 0}, DeleteTrack{Track: 0}, SetTrackMute{Track: 0, Mute: true}}, 3, "track 1 does not exist, the project has 0 tracks after action 2 deleted track 1"},
+		{"volume out of range", []Action{SetTrackVolume{Track: 0, VolumeDB: 30}}, 0, "out of range"},
+		{"unknown handle", []Action{SetTrackMute{Track: 0, TrackRef: "t9"}}, 0, `unknown track handle "t9"`},
+		{
+			"deleted handle",
+			[]Action{CreateTrack{Index: 1, Ref: "t1"}, DeleteTrack{Track: 1, TrackRef: "t1"}, SetTrackMute{Track: 1, TrackRef: "t1"}},
+			2, `track "t1" was deleted by action 1`,
+		},
+		{"handle in use", []Action{CreateTrack{Index: 1, Ref: "t1"}, CreateTrack{Index: 2, Ref: "t1"}}, 1, `track handle "t1" is already in use`},
+	}
+	state := &ProjectState{Tracks: []TrackState{{Name: "Bass"}}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply(state, tt.actions)
+			var applyErr *ApplyError
+			if !errors.As(err, &applyErr) {
+				t.Fatalf("Apply() error = %v, want *ApplyError", err)
+			}
+			if applyErr.Index != tt.index || !strings.Contains(applyErr.Msg, tt.want) {
+				t.Errorf("Apply() error = %v, want action %d: %q", err, tt.index, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply_recreatedTrack(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "Bass"}}}
+	actions := []Action{DeleteTrack{Track: 0}, CreateTrack{Name: "Keys", Index: 0}, SetTrackMute{Track: 0, Mute: true}}
+	got, err := Apply(state, actions)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []TrackState{{Name: "Keys", Mute: true}}; !reflect.DeepEqual(got.Tracks, want) {
+		t.Errorf("Apply() tracks = %+v, want %+v", got.Tracks, want)
+	}
+}
+
+func TestApply_shiftedIndices(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "A"}, {Name: "B"}, {Name: "C"}, {Name: "D"}}}
+	tests := []struct {
+		name    string
+		actions []Action
+		want    []string // Track names, muted ones suffixed with *
+	}{
+		{"index reused after delete", []Action{DeleteTrack{Track: 1}, SetTrackMute{Track: 1, Mute: true}}, []string{"A", "C*", "D"}},
+		{"range deleted from the front", []Action{DeleteTrack{Track: 1}, DeleteTrack{Track: 0}, SetTrackMute{Track: 0, Mute: true}}, []string{"C*", "D"}},
+		{"later track after delete", []Action{DeleteTrack{Track: 1}, SetTrackMute{Track: 2, Mute: true}}, []string{"A", "C", "D*"}},
+		{"insert then delete", []Action{CreateTrack{Name: "E", Index: 0}, DeleteTrack{Track: 2}, SetTrackMute{Track: 2, Mute: true}}, []string{"E", "A", "C*", "D"}},
+		{"delete then insert", []Action{DeleteTrack{Track: 3}, CreateTrack{Name: "E", Index: 0}, SetTrackMute{Track: 3, Mute: true}}, []string{"E", "A", "B", "C*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(state, tt.actions)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			var names []string
+			for _, track := range got.Tracks {
+				if track.Mute {
+					track.Name += "*"
+				}
+				names = append(names, track.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("Apply() tracks = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply_trackHandles(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "Drums"}}}
+
+	// The parser predicted index 1 for Bass, but Keys is inserted before it
+	actions := []Action{
+		CreateTrack{Name: "Bass", Index: 1, Ref: "t1"},
+		CreateTrack{Name: "Keys", Index: 0},
+		SetTrackMute{Track: 1, TrackRef: "t1", Mute: true},
+		DeleteTrack{Track: 0},
+		AddTrackFX{Track: 1, TrackRef: "t1", FXName: "ReaEQ"},
+	}
+	got, err := Apply(state, actions)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []TrackState{{Name: "Drums"}, {Name: "Bass", Mute: true, FX: []string{"ReaEQ"}, Ref: "t1"}}
+	if !reflect.DeepEqual(got.Tracks, want) {
+		t.Errorf("Apply() tracks = %+v, want %+v", got.Tracks, want)
+	}
+
+	// The state keeps the handle for later batches
+	got, err = Apply(got, []Action{SetTrackSolo{Track: 0, TrackRef: "t1", Solo: true}})
+	if err != nil {
+		t.Fatalf("second Apply() error = %v", err)
+	}
+	if !got.Tracks[1].Solo {
+		t.Errorf("second Apply() did not solo the track with handle t1: %+v", got.Tracks)
+	}
+}
@@ -0,0 +1,295 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pos is a location in DSL source code
+type Pos struct {
+	Offset int // Byte offset, 0-based
+	Line   int // Line number, 1-based
+	Column int // Column in bytes, 1-based
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Program is the root of a parsed DSL source: a sequence of statements
+type Program struct {
+	Statements []*Statement
+	Comments   []*Comment // All comments in source order; the parser otherwise ignores them
+}
+
+// Comment is a line comment, running from # or // to the end of the line, or a /* block */ comment
+type Comment struct {
+	Pos  Pos
+	Text string // Comment text including its delimiters; line comments drop trailing whitespace
+}
+
+// Statement is a track call followed by an optional method chain
+// Example: track(instrument="Serum").newClip(bar=1).setVolume(volume_db=-3)
+// A statement can also start with a name bound by an earlier "as" or "let": bass.setVolume(volume_db=-3)
+type Statement struct {
+	Pos    Pos
+	Track  *TrackCall    // nil for a bare chain like .newClip(bar=1), which targets the selected track
+	Bind   *Ident        // Name bound by track(...) as name or let name = ...; nil if none
+	Let    bool          // Bind was written as let name = ...
+	Value  *Literal      // Value of let name = value; Track, Target and Chain are empty
+	Target *Ident        // Bound name the chain applies to, as in bass.newClip(bar=1); Track is nil
+	Chain  []*MethodCall // Chained method calls in source order
+	End    int           // Byte offset just past the statement
+}
+
+// Ident is a name in the source, such as bass in track(name="Bass") as bass
+type Ident struct {
+	Pos  Pos
+	Name string
+}
+
+// TrackCall is a track(...) call, either creating a track or referencing an existing one,
+// or a tracks(...) selector referencing every existing track it matches
+type TrackCall struct {
+	Pos      Pos
+	End      int  // Byte offset just past the closing parenthesis
+	Selector bool // tracks(...) rather than track(...)
+	Args     []*Arg
+}
+
+// Arg returns the keyword argument with the given name, or nil
+func (c *TrackCall) Arg(name string) *Arg {
+	return findArg(c.Args, name)
+}
+
+// MethodCall is a chained method call such as .newClip(bar=1, length_bars=4)
+type MethodCall struct {
+	Pos  Pos
+	End  int    // Byte offset just past the closing parenthesis
+	Name string // Method name as written, without the leading dot
+	Args []*Arg
+}
+
+// Arg returns the keyword argument with the given name, or nil
+func (c *MethodCall) Arg(name string) *Arg {
+	return findArg(c.Args, name)
+}
+
+// Arg is a call argument: keyword (name=value), pattern (name~value) or positional (value)
+type Arg struct {
+	Pos   Pos
+	Name  string // Empty for positional arguments
+	Match bool   // name~value: a pattern match in a tracks(...) selector
+	Value *Literal
+}
+
+// findArg returns the first keyword argument named name
+func findArg(args []*Arg, name string) *Arg {
+	for _, arg := range args {
+		if arg.Name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+// LiteralKind identifies the type of a literal value
+type LiteralKind int
+
+const (
+	// StringLiteral is a double-quoted string
+	StringLiteral LiteralKind = iota
+	// NumberLiteral is an integer or decimal number
+	NumberLiteral
+	// BoolLiteral is true or false
+	BoolLiteral
+	// ArrayLiteral is a bracketed list of values: [1, 2, 3]
+	ArrayLiteral
+	// ObjectLiteral is a braced list of key=value fields: {pitch=60, velocity=100}
+	ObjectLiteral
+	// RangeLiteral is an inclusive range of integers in a tracks(...) selector: 1..4
+	RangeLiteral
+	// KeywordLiteral is a bare keyword in a tracks(...) selector: all
+	KeywordLiteral
+	// NameLiteral is a name bound with let, standing for its value: bars
+	NameLiteral
+)
+
+func (k LiteralKind) String() string {
+	switch k {
+	case StringLiteral:
+		return "string"
+	case NumberLiteral:
+		return "number"
+	case BoolLiteral:
+		return "boolean"
+	case ArrayLiteral:
+		return "array"
+	case ObjectLiteral:
+		return "object"
+	case RangeLiteral:
+		return "range"
+	case KeywordLiteral:
+		return "keyword"
+	case NameLiteral:
+		return "name"
+	default:
+		return fmt.Sprintf("LiteralKind(%d)", int(k))
+	}
+}
+
+// Literal is a literal value in an argument, array or object
+type Literal struct {
+	Pos    Pos
+	Kind   LiteralKind
+	Text   string     // Unescaped contents for strings, source text for numbers, keywords and names, "true"/"false" for booleans, "1..4" for ranges
+	Elems  []*Literal // Elements of an ArrayLiteral, or the bounds of a RangeLiteral
+	Fields []*Arg     // Fields of an ObjectLiteral
+}
+
+// Int returns the literal as an integer
+// Only number literals without a fractional part convert.
+func (l *Literal) Int() (int, bool) {
+	if l == nil || l.Kind != NumberLiteral {
+		return 0, false
+	}
+	n, err := strconv.Atoi(l.Text)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// Float returns the literal as a float64
+func (l *Literal) Float() (float64, bool) {
+	if l == nil || l.Kind != NumberLiteral {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(l.Text, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// Bool returns the literal as a boolean
+func (l *Literal) Bool() (bool, bool) {
+	if l == nil || l.Kind != BoolLiteral {
+		return false, false
+	}
+	return l.Text == BooleanTrue, true
+}
+
+// Str returns the contents of a string literal
+func (l *Literal) Str() (string, bool) {
+	if l == nil || l.Kind != StringLiteral {
+		return "", false
+	}
+	return l.Text, true
+}
+
+// Field returns the object field with the given name, or nil
+func (l *Literal) Field(name string) *Arg {
+	if l == nil || l.Kind != ObjectLiteral {
+		return nil
+	}
+	return findArg(l.Fields, name)
+}
+
+// describe returns the literal's source text for scalars, or its kind for arrays and objects
+func (l *Literal) describe() string {
+	switch l.Kind {
+	case StringLiteral:
+		return strconv.Quote(l.Text)
+	case NumberLiteral, BoolLiteral:
+		return l.Text
+	default:
+		return l.Kind.String()
+	}
+}
+
+// String returns the statement as single-line DSL source
+func (s *Statement) String() string {
+	var b strings.Builder
+	if s.Let {
+		b.WriteString(letKeyword + " " + s.Bind.Name + " = ")
+	}
+	if s.Value != nil {
+		b.WriteString(s.Value.String())
+	}
+	if s.Track != nil {
+		b.WriteString(s.Track.String())
+	}
+	if s.Bind != nil && !s.Let {
+		b.WriteString(" " + asKeyword + " " + s.Bind.Name)
+	}
+	if s.Target != nil {
+		b.WriteString(s.Target.Name)
+	}
+	for _, call := range s.Chain {
+		b.WriteString(call.String())
+	}
+	return b.String()
+}
+
+// String returns the call as DSL source, e.g. track(name="Bass")
+func (c *TrackCall) String() string {
+	return c.keyword() + "(" + argsString(c.Args) + ")"
+}
+
+// keyword returns "tracks" for a selector and "track" otherwise
+func (c *TrackCall) keyword() string {
+	if c.Selector {
+		return tracksKeyword
+	}
+	return trackKeyword
+}
+
+// String returns the call as DSL source, including the leading dot, e.g. .newClip(bar=1)
+func (c *MethodCall) String() string {
+	return "." + c.Name + "(" + argsString(c.Args) + ")"
+}
+
+// String returns the argument as DSL source, e.g. volume_db=-3
+func (a *Arg) String() string {
+	if a.Name == "" {
+		return a.Value.String()
+	}
+	if a.Match {
+		return a.Name + "~" + a.Value.String()
+	}
+	return a.Name + "=" + a.Value.String()
+}
+
+// String returns the literal as DSL source, quoting and escaping strings
+func (l *Literal) String() string {
+	switch l.Kind {
+	case StringLiteral:
+		return quoteString(l.Text)
+	case ArrayLiteral:
+		elems := make([]string, len(l.Elems))
+		for i, elem := range l.Elems {
+			elems[i] = elem.String()
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case ObjectLiteral:
+		return "{" + argsString(l.Fields) + "}"
+	default:
+		return l.Text
+	}
+}
+
+func argsString(args []*Arg) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// quoteString writes s as a DSL string literal, using the escapes the lexer understands
+func quoteString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,140 @@
+// Command dslfmt formats MAGDA DSL programs
+//
+// Usage:
+//
+//	dslfmt [-w] [-l] [-width n] [file ...]
+//
+// Without files, dslfmt formats standard input to standard output. With files, it prints
+// the formatted source of each, or with -w rewrites the files in place. -l lists the files
+// whose formatting differs instead. Parse errors are reported with their position and
+// source snippet, and make dslfmt exit with status 1.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	dsl "github.com/Conceptual-Machines/magda-dsl/parsers/go"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes dslfmt with the given arguments and returns the exit status
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dslfmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result to the source file instead of standard output")
+	list := flags.Bool("l", false, "list files whose formatting differs from dslfmt's")
+	width := flags.Int("width", dsl.DefaultFormatWidth, "line width past which chains get one method per line")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: dslfmt [-w] [-l] [-width n] [file ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	opts := dsl.FormatOptions{Width: *width}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "dslfmt: cannot use -w with standard input")
+			return 2
+		}
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "dslfmt: %v\n", err)
+			return 1
+		}
+		if !formatFile("<stdin>", src, opts, *list, stdout, stderr) {
+			return 1
+		}
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "dslfmt: %v\n", err)
+			status = 1
+			continue
+		}
+		if *write {
+			if !rewriteFile(path, src, opts, *list, stdout, stderr) {
+				status = 1
+			}
+			continue
+		}
+		if !formatFile(path, src, opts, *list, stdout, stderr) {
+			status = 1
+		}
+	}
+	return status
+}
+
+// formatFile prints the formatted source, or its name if -l is set and it changed
+func formatFile(name string, src []byte, opts dsl.FormatOptions, list bool, stdout, stderr io.Writer) bool {
+	out, ok := format(name, src, opts, stderr)
+	if !ok {
+		return false
+	}
+	if list {
+		if !bytes.Equal(out, src) {
+			fmt.Fprintln(stdout, name)
+		}
+		return true
+	}
+	_, _ = stdout.Write(out)
+	return true
+}
+
+// rewriteFile formats a file in place, leaving it untouched if it is already formatted
+func rewriteFile(path string, src []byte, opts dsl.FormatOptions, list bool, stdout, stderr io.Writer) bool {
+	out, ok := format(path, src, opts, stderr)
+	if !ok {
+		return false
+	}
+	if bytes.Equal(out, src) {
+		return true
+	}
+	if list {
+		fmt.Fprintln(stdout, path)
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		fmt.Fprintf(stderr, "dslfmt: %v\n", err)
+		return false
+	}
+	return true
+}
+
+func format(name string, src []byte, opts dsl.FormatOptions, stderr io.Writer) ([]byte, bool) {
+	out, err := dsl.Format(string(src), opts)
+	if err != nil {
+		reportError(name, err, stderr)
+		return nil, false
+	}
+	return []byte(out), true
+}
+
+// reportError prints "name:line:column: message" followed by the source snippet
+func reportError(name string, err error, stderr io.Writer) {
+	var perr *dsl.ParseError
+	if !errors.As(err, &perr) {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return
+	}
+	msg := perr.Msg
+	if perr.Method != "" {
+		msg = perr.Method + ": " + msg
+	}
+	fmt.Fprintf(stderr, "%s:%d:%d: %s\n", name, perr.Pos.Line, perr.Pos.Column, msg)
+	if perr.Snippet != "" {
+		fmt.Fprintln(stderr, perr.Snippet)
+	}
+}
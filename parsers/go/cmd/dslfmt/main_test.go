@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_stdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := run(nil, strings.NewReader("track( id = 1 ).setMute(mute=true)"), &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("run() = %d, stderr:\n%s", status, stderr.String())
+	}
+	if want := "track(id=1).set_mute(mute=true)\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRun_width(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"-width", "20"}, strings.NewReader("track(id=1).set_mute(mute=true)"), &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("run() = %d, stderr:\n%s", status, stderr.String())
+	}
+	if want := "track(id=1)\n  .set_mute(mute=true)\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRun_parseError(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := run(nil, strings.NewReader("track(id=1)\n.set_volume(volume_db=)"), &stdout, &stderr)
+	if status != 1 {
+		t.Fatalf("run() = %d, want 1", status)
+	}
+	if got := stderr.String(); !strings.HasPrefix(got, "<stdin>:2:") || !strings.Contains(got, "^") {
+		t.Errorf("stderr = %q, want a positioned error with a snippet", got)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want nothing", stdout.String())
+	}
+}
+
+func TestRun_files(t *testing.T) {
+	dir := t.TempDir()
+	messy := filepath.Join(dir, "messy.magda")
+	tidy := filepath.Join(dir, "tidy.magda")
+	if err := os.WriteFile(messy, []byte("track(id=1) . setPan( pan=0.5 )"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tidy, []byte("track(id=2)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"-l", messy, tidy}, nil, &stdout, &stderr); status != 0 {
+		t.Fatalf("run(-l) = %d, stderr:\n%s", status, stderr.String())
+	}
+	if stdout.String() != messy+"\n" {
+		t.Errorf("run(-l) stdout = %q, want only %q", stdout.String(), messy)
+	}
+
+	stdout.Reset()
+	if status := run([]string{"-w", messy, tidy}, nil, &stdout, &stderr); status != 0 {
+		t.Fatalf("run(-w) = %d, stderr:\n%s", status, stderr.String())
+	}
+	got, err := os.ReadFile(messy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "track(id=1).set_pan(pan=0.5)\n"; string(got) != want {
+		t.Errorf("rewritten file = %q, want %q", got, want)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("run(-w) stdout = %q, want nothing", stdout.String())
+	}
+}
+
+func TestRun_stdinWithW(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"-w"}, strings.NewReader("track()"), &stdout, &stderr); status != 2 {
+		t.Errorf("run(-w) on stdin = %d, want 2", status)
+	}
+}
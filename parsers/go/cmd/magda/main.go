@@ -0,0 +1,317 @@
+// Command magda parses, checks and formats MAGDA DSL programs from the shell
+//
+// Usage:
+//
+//	magda parse [-state file] [-canonical] [-resolve-names] [-handles] [file]
+//	magda check [-state file] [-canonical] [-resolve-names] [-handles] [file ...]
+//	magda fmt [-w] [-l] [-width n] [file ...]
+//	magda state file
+//	magda repl [-state file] [-canonical] [-resolve-names] [-handles]
+//
+// parse prints the actions of a program as a JSON array. check only validates, printing
+// every error with its position and source snippet. fmt prints programs in canonical layout,
+// like dslfmt. state loads a DAW state JSON file and lists its tracks as the parser sees
+// them; parse and check take the same file with -state, to resolve track(selected=true) and
+// track(ref=...).
+// repl evaluates one line at a time, printing its actions and applying them to a simulated
+// copy of the state; type :help at its prompt for its commands.
+// Without files, programs are read from standard input. Errors make magda exit with status 1,
+// and usage errors with status 2.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	dsl "github.com/Conceptual-Machines/magda-dsl/parsers/go"
+)
+
+// stdinName names standard input in diagnostics
+const stdinName = "<stdin>"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// command is a magda subcommand
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init because usage refers back to commands
+	commands = []command{
+		{"parse", "parse [-state file] [-canonical] [-resolve-names] [-handles] [file]", "print the actions of a program as JSON", runParse},
+		{"check", "check [-state file] [-canonical] [-resolve-names] [-handles] [file ...]", "validate programs and report every error", runCheck},
+		{"fmt", "fmt [-w] [-l] [-width n] [file ...]", "print programs in canonical layout", runFmt},
+		{"state", "state file", "load a DAW state file and list its tracks", runState},
+		{"repl", "repl [-state file] [-canonical] [-resolve-names] [-handles]", "evaluate lines interactively against a simulated state", runRepl},
+	}
+}
+
+// run executes magda with the given arguments and returns the exit status
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	// The parser logs a line per program; the CLI reports through its own output instead
+	log.SetOutput(io.Discard)
+
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return 0
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdin, stdout, stderr)
+		}
+	}
+	fmt.Fprintf(stderr, "magda: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: magda <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-45s %s\n", cmd.usage, cmd.summary)
+	}
+}
+
+// newFlagSet returns a flag set that reports errors and usage for one subcommand
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("magda "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		for _, cmd := range commands {
+			if cmd.name == name {
+				fmt.Fprintln(stderr, "usage: magda "+cmd.usage)
+			}
+		}
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parserFlags are the flags shared by parse, check and repl
+type parserFlags struct {
+	state        *string
+	canonical    *bool
+	resolveNames *bool
+	handles      *bool
+}
+
+func addParserFlags(flags *flag.FlagSet) parserFlags {
+	return parserFlags{
+		state:        flags.String("state", "", "DAW state JSON file used to resolve track references"),
+		canonical:    flags.Bool("canonical", false, "reject camelCase method aliases"),
+		resolveNames: flags.Bool("resolve-names", false, "resolve track(name=...) to an existing track of that name"),
+		handles:      flags.Bool("handles", false, "give created tracks handles that later actions reference with track_ref"),
+	}
+}
+
+// newParser returns a parser configured from the flags
+func (pf parserFlags) newParser() (*dsl.Parser, error) {
+	parser := dsl.NewParser()
+	parser.SetCanonicalMethodsOnly(*pf.canonical)
+	parser.SetResolveTrackNames(*pf.resolveNames)
+	parser.SetTrackHandles(*pf.handles)
+	if *pf.state != "" {
+		state, err := loadState(*pf.state)
+		if err != nil {
+			return nil, err
+		}
+		parser.SetState(state)
+	}
+	return parser, nil
+}
+
+func runParse(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("parse", stderr)
+	pf := addParserFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	parser, err := pf.newParser()
+	if err != nil {
+		fmt.Fprintf(stderr, "magda: %v\n", err)
+		return 1
+	}
+	name, src, err := readSource(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "magda: %v\n", err)
+		return 1
+	}
+
+	actions, err := parser.ParseProgram(string(src))
+	if err != nil {
+		reportError(name, err, stderr)
+		return 1
+	}
+	if actions == nil {
+		// A program of bindings only prints an empty list rather than null
+		actions = []dsl.Action{}
+	}
+	data, err := json.MarshalIndent(actions, "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "magda: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, string(data))
+	return 0
+}
+
+func runCheck(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("check", stderr)
+	pf := addParserFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+	status := 0
+	for _, path := range paths {
+		parser, err := pf.newParser()
+		if err != nil {
+			fmt.Fprintf(stderr, "magda: %v\n", err)
+			return 1
+		}
+		// Recover from errors so one run reports all of them
+		parser.SetErrorRecovery(true)
+
+		name, src, err := readSource(path, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "magda: %v\n", err)
+			status = 1
+			continue
+		}
+		actions, err := parser.ParseProgram(string(src))
+		if err != nil {
+			reportError(name, err, stderr)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: ok, %d actions\n", name, len(actions))
+	}
+	return status
+}
+
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("fmt", stderr)
+	write := flags.Bool("w", false, "write the result to the source file instead of standard output")
+	list := flags.Bool("l", false, "list files whose formatting differs from magda fmt's")
+	width := flags.Int("width", dsl.DefaultFormatWidth, "line width past which chains get one method per line")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *write && flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "magda: cannot use -w with standard input")
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+	status := 0
+	for _, path := range paths {
+		name, src, err := readSource(path, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "magda: %v\n", err)
+			status = 1
+			continue
+		}
+		formatted, err := dsl.Format(string(src), dsl.FormatOptions{Width: *width})
+		if err != nil {
+			reportError(name, err, stderr)
+			status = 1
+			continue
+		}
+
+		out := []byte(formatted)
+		changed := !bytes.Equal(out, src)
+		switch {
+		case *list:
+			if changed {
+				fmt.Fprintln(stdout, name)
+			}
+		case !*write:
+			_, _ = stdout.Write(out)
+		}
+		if *write && changed {
+			if err := os.WriteFile(path, out, 0o644); err != nil {
+				fmt.Fprintf(stderr, "magda: %v\n", err)
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+// readSource reads a program from path, or from stdin if path is empty or "-"
+func readSource(path string, stdin io.Reader) (name string, src []byte, err error) {
+	if path == "" || path == "-" {
+		src, err = io.ReadAll(stdin)
+		return stdinName, src, err
+	}
+	src, err = os.ReadFile(path)
+	return path, src, err
+}
+
+// reportError prints each parse error as "name:line:column: message" followed by the source snippet
+func reportError(name string, err error, stderr io.Writer) {
+	errs, ok := parseErrors(err)
+	if !ok {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return
+	}
+	for _, perr := range errs {
+		fmt.Fprintf(stderr, "%s:%d:%d: %s\n", name, perr.Pos.Line, perr.Pos.Column, errorMessage(perr))
+		if perr.Snippet != "" {
+			fmt.Fprintln(stderr, perr.Snippet)
+		}
+	}
+}
+
+// parseErrors returns the parse errors in err, whether it holds one or several
+func parseErrors(err error) (dsl.ParseErrors, bool) {
+	var errs dsl.ParseErrors
+	if errors.As(err, &errs) {
+		return errs, true
+	}
+	var perr *dsl.ParseError
+	if errors.As(err, &perr) {
+		return dsl.ParseErrors{perr}, true
+	}
+	return nil, false
+}
+
+// errorMessage returns "method: message", or just the message for syntax errors
+func errorMessage(perr *dsl.ParseError) string {
+	if perr.Method != "" {
+		return perr.Method + ": " + perr.Msg
+	}
+	return perr.Msg
+}
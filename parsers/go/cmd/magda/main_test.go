@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runMagda runs magda with args and stdin, returning the status and outputs
+func runMagda(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+// writeFile writes content to name in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun_usage(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+	}{
+		{"no command", nil, 2},
+		{"unknown command", []string{"explode"}, 2},
+		{"help", []string{"help"}, 0},
+		{"bad flag", []string{"parse", "-nope"}, 2},
+		{"parse with two files", []string{"parse", "a", "b"}, 2},
+		{"state without file", []string{"state"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, stdout, stderr := runMagda(t, "", tt.args...)
+			if status != tt.wantStatus {
+				t.Errorf("run(%v) = %d, want %d", tt.args, status, tt.wantStatus)
+			}
+			if !strings.Contains(stdout+stderr, "usage: magda") {
+				t.Errorf("run(%v) printed no usage:\n%s%s", tt.args, stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestRunParse(t *testing.T) {
+	status, stdout, stderr := runMagda(t, `track(name="Bass").setVolume(volume_db=-3)`, "parse")
+	if status != 0 {
+		t.Fatalf("parse = %d, stderr:\n%s", status, stderr)
+	}
+	var actions []map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &actions); err != nil {
+		t.Fatalf("parse output is not JSON: %v\n%s", err, stdout)
+	}
+	if len(actions) != 2 || actions[0]["action"] != "create_track" || actions[1]["volume_db"] != -3.0 {
+		t.Errorf("parse actions = %v", actions)
+	}
+}
+
+func TestRunParse_state(t *testing.T) {
+	state := writeFile(t, "state.json", `{"state": {"tracks": [{"name": "Bass"}, {"name": "Lead", "selected": true}]}}`)
+	program := writeFile(t, "song.magda", `track(selected=true).setMute(mute=true)`)
+
+	status, stdout, stderr := runMagda(t, "", "parse", "-state", state, program)
+	if status != 0 {
+		t.Fatalf("parse -state = %d, stderr:\n%s", status, stderr)
+	}
+	if !strings.Contains(stdout, `"track": 1`) {
+		t.Errorf("parse -state did not resolve the selected track:\n%s", stdout)
+	}
+
+	status, _, stderr = runMagda(t, "", "parse", program)
+	if status != 1 || !strings.Contains(stderr, "song.magda:1:1: track: no selected track found in state") {
+		t.Errorf("parse without state = %d, stderr:\n%s", status, stderr)
+	}
+
+	named := writeFile(t, "named.magda", `track(name="bass").addFX(fxname="ReaVerb")`)
+	status, stdout, stderr = runMagda(t, "", "parse", "-state", state, "-resolve-names", named)
+	if status != 0 || !strings.Contains(stdout, `"action": "add_track_fx"`) || strings.Contains(stdout, "create_track") {
+		t.Errorf("parse -resolve-names = %d, stdout:\n%s\nstderr:\n%s", status, stdout, stderr)
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	good := writeFile(t, "good.magda", "# fine\ntrack(id=1).set_pan(pan=0.5)")
+	bad := writeFile(t, "bad.magda", "track().setVolum(volume_db=1)\ntrack(name=)")
+
+	status, stdout, stderr := runMagda(t, "", "check", good, bad)
+	if status != 1 {
+		t.Errorf("check = %d, want 1", status)
+	}
+	if stdout != good+": ok, 1 actions\n" {
+		t.Errorf("check stdout = %q", stdout)
+	}
+	for _, want := range []string{
+		bad + `:1:8: setVolum: unknown method "setVolum", did you mean "setVolume"?`,
+		"track().setVolum(volume_db=1)\n       ^~~~~~~~~",
+		bad + ":2:12: ",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("check stderr missing %q:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestRunCheck_onlyComments(t *testing.T) {
+	status, _, stderr := runMagda(t, "# just a comment\n", "check")
+	if status != 1 || !strings.Contains(stderr, "<stdin>:1:1: no actions found in DSL code") {
+		t.Errorf("check = %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestRunCheck_canonical(t *testing.T) {
+	status, _, stderr := runMagda(t, `track().setPan(pan=0)`, "check", "-canonical")
+	if status != 1 || !strings.Contains(stderr, `<stdin>:1:8: setPan: `) {
+		t.Errorf("check -canonical = %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestRunFmt(t *testing.T) {
+	if status, _, stderr := runMagda(t, "track()", "fmt", "-w"); status != 2 || !strings.Contains(stderr, "cannot use -w with standard input") {
+		t.Errorf("fmt -w on stdin = %d, stderr %q", status, stderr)
+	}
+
+	status, stdout, stderr := runMagda(t, "track( id = 1 ).setMute(mute=true) # quiet", "fmt")
+	if status != 0 {
+		t.Fatalf("fmt = %d, stderr:\n%s", status, stderr)
+	}
+	if want := "track(id=1).set_mute(mute=true) # quiet\n"; stdout != want {
+		t.Errorf("fmt stdout = %q, want %q", stdout, want)
+	}
+
+	path := writeFile(t, "song.magda", "track(id=1) . setPan( pan=0.5 )")
+	if status, stdout, _ := runMagda(t, "", "fmt", "-l", "-w", path); status != 0 || stdout != path+"\n" {
+		t.Errorf("fmt -l -w = %d, stdout %q", status, stdout)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "track(id=1).set_pan(pan=0.5)\n"; string(got) != want {
+		t.Errorf("rewritten file = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	dsl "github.com/Conceptual-Machines/magda-dsl/parsers/go"
+)
+
+const replPrompt = "magda> "
+
+const replHelp = `Enter DSL statements, one per line. A line starting with .method(...) continues on
+the track of the previous line.
+
+  :state        print the simulated project state as JSON
+  :tracks       list the tracks of the simulated state
+  :undo         undo the last line
+  :load FILE    load a DAW state file and start over from it
+  :help         show this help
+  :quit         leave the REPL`
+
+// session is a REPL session: a parser and the simulated state its lines have produced
+// The parser follows the state the lines produce, so its track counter and bindings carry
+// over from line to line. Accepted lines are kept so :undo can rebuild the parser and state
+// by replaying them.
+type session struct {
+	newParser func() *dsl.Parser
+	initial   *dsl.ProjectState
+	lines     []string // Accepted sources, in order
+	parser    *dsl.Parser
+	state     *dsl.ProjectState
+	track     int // Track of the last action, continued by lines starting with "."; -1 if none
+}
+
+func newSession(newParser func() *dsl.Parser, initial *dsl.ProjectState) (*session, error) {
+	if initial == nil {
+		initial = &dsl.ProjectState{Tracks: []dsl.TrackState{}}
+	}
+	s := &session{newParser: newParser, initial: initial}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay rebuilds the parser and state from the initial state and the accepted lines
+func (s *session) replay() error {
+	s.parser = s.newParser()
+	s.parser.SetState(s.initial)
+	s.state = s.initial
+	s.track = -1
+	for _, src := range s.lines {
+		if _, err := s.apply(src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eval parses a line, applies its actions to the state and returns them
+// A line that fails leaves the session as it was.
+func (s *session) eval(line string) ([]dsl.Action, error) {
+	src := line
+	if strings.HasPrefix(strings.TrimSpace(line), ".") {
+		if s.track < 0 {
+			return nil, errors.New("no track to continue: start the line with track(...)")
+		}
+		// The user's line stays on a line of its own, so error positions and snippets match it
+		src = fmt.Sprintf("track(id=%d)\n%s", s.track+1, line)
+	}
+
+	actions, err := s.apply(src)
+	if err != nil {
+		// The parser advanced past a program that Apply then rejected
+		if replayErr := s.replay(); replayErr != nil {
+			return nil, replayErr
+		}
+		return nil, err
+	}
+	s.lines = append(s.lines, src)
+	return actions, nil
+}
+
+func (s *session) apply(src string) ([]dsl.Action, error) {
+	actions, err := s.parser.ParseProgram(src)
+	if err != nil {
+		return nil, err
+	}
+	state, err := dsl.Apply(s.state, actions)
+	if err != nil {
+		return nil, err
+	}
+	s.state = state
+	if len(actions) == 0 {
+		// A line of bindings only, such as let bars = 8
+		return actions, nil
+	}
+	if track, ok := actionTrack(actions[len(actions)-1]); ok {
+		s.track = track
+	}
+	return actions, nil
+}
+
+// undo drops the last accepted line and reports whether there was one
+func (s *session) undo() (bool, error) {
+	if len(s.lines) == 0 {
+		return false, nil
+	}
+	s.lines = s.lines[:len(s.lines)-1]
+	return true, s.replay()
+}
+
+// load starts over from a new initial state
+func (s *session) load(state *dsl.ProjectState) error {
+	s.initial = state
+	s.lines = nil
+	return s.replay()
+}
+
+// actionTrack returns the track an action applies to; false for a deleted track
+func actionTrack(action dsl.Action) (int, bool) {
+	switch a := action.(type) {
+	case dsl.CreateTrack:
+		return a.Index, true
+	case dsl.DeleteTrack:
+		return 0, false
+	}
+	track, ok := action.Map()["track"].(int)
+	return track, ok
+}
+
+func runRepl(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("repl", stderr)
+	pf := addParserFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var initial *dsl.ProjectState
+	if *pf.state != "" {
+		state, err := loadState(*pf.state)
+		if err != nil {
+			fmt.Fprintf(stderr, "magda: %v\n", err)
+			return 1
+		}
+		initial = state
+	}
+	newParser := func() *dsl.Parser {
+		parser := dsl.NewParser()
+		parser.SetCanonicalMethodsOnly(*pf.canonical)
+		parser.SetResolveTrackNames(*pf.resolveNames)
+		parser.SetTrackHandles(*pf.handles)
+		return parser
+	}
+	s, err := newSession(newParser, initial)
+	if err != nil {
+		fmt.Fprintf(stderr, "magda: %v\n", err)
+		return 1
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, replPrompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			break
+		}
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if quit := s.command(strings.Fields(line), stdout); quit {
+				break
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		actions, err := s.eval(line)
+		if err != nil {
+			reportReplError(err, stdout)
+			continue
+		}
+		for _, action := range actions {
+			data, _ := json.Marshal(action)
+			fmt.Fprintln(stdout, string(data))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(stderr, "magda: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// command runs a :command and reports whether the REPL should quit
+func (s *session) command(fields []string, out io.Writer) bool {
+	switch fields[0] {
+	case ":quit", ":q", ":exit":
+		return true
+	case ":help":
+		fmt.Fprintln(out, replHelp)
+	case ":state":
+		data, err := json.MarshalIndent(s.state, "", "  ")
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			break
+		}
+		fmt.Fprintln(out, string(data))
+	case ":tracks":
+		printTracks(out, s.state)
+	case ":undo":
+		undone, err := s.undo()
+		switch {
+		case err != nil:
+			fmt.Fprintf(out, "error: %v\n", err)
+		case undone:
+			fmt.Fprintf(out, "undone, %d lines left\n", len(s.lines))
+		default:
+			fmt.Fprintln(out, "nothing to undo")
+		}
+	case ":load":
+		if len(fields) != 2 {
+			fmt.Fprintln(out, "usage: :load FILE")
+			break
+		}
+		state, err := loadState(fields[1])
+		if err == nil {
+			err = s.load(state)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			break
+		}
+		fmt.Fprintf(out, "loaded %s, %d tracks\n", fields[1], len(s.state.Tracks))
+	default:
+		fmt.Fprintf(out, "unknown command %s, try :help\n", fields[0])
+	}
+	return false
+}
+
+// reportReplError prints errors for the entered line, with the column and snippet of each
+func reportReplError(err error, out io.Writer) {
+	errs, ok := parseErrors(err)
+	if !ok {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return
+	}
+	for _, perr := range errs {
+		fmt.Fprintf(out, "error: column %d: %s\n", perr.Pos.Column, errorMessage(perr))
+		if perr.Snippet != "" {
+			fmt.Fprintln(out, perr.Snippet)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	dsl "github.com/Conceptual-Machines/magda-dsl/parsers/go"
+)
+
+func TestRunRepl(t *testing.T) {
+	input := strings.Join([]string{
+		`track(name="Bass")`,
+		`.newClip(bar=1, length_bars=4)`,
+		`.setVolum(volume_db=-3)`,
+		`track(name="Lead").setSelected(selected=true)`,
+		`:tracks`,
+		`:undo`,
+		`:tracks`,
+		`track(name="Pad")`,
+		`:bogus`,
+		`:quit`,
+		`track(name="never")`,
+	}, "\n")
+	status, stdout, stderr := runMagda(t, input, "repl")
+	if status != 0 {
+		t.Fatalf("repl = %d, stderr:\n%s", status, stderr)
+	}
+
+	for _, want := range []string{
+		`{"action":"create_track","index":0,"name":"Bass"}`,
+		`{"action":"create_clip_at_bar","bar":1,"length_bars":4,"track":0}`,
+		"error: column 1: setVolum: unknown method \"setVolum\", did you mean \"setVolume\"?\n.setVolum(volume_db=-3)\n^~~~~~~~~",
+		`{"action":"set_track_selected","selected":true,"track":1}`,
+		"2   \"Lead\"  true",
+		"undone, 2 lines left",
+		// Undo also rewinds the track counter, so Pad takes Lead's index
+		`{"action":"create_track","index":1,"name":"Pad"}`,
+		"unknown command :bogus",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("repl output missing %q:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "never") {
+		t.Errorf("repl kept reading after :quit:\n%s", stdout)
+	}
+}
+
+func TestRunRepl_stateAndLoad(t *testing.T) {
+	state := writeFile(t, "state.json", `{"tracks": [{"name": "Drums"}, {"name": "Keys", "selected": true}]}`)
+	input := strings.Join([]string{
+		`.setMute(mute=true)`,
+		`track(selected=true).setSolo(solo=true)`,
+		`:state`,
+		`:load ` + state + `.missing`,
+		`:load ` + state,
+		`:undo`,
+	}, "\n")
+	status, stdout, stderr := runMagda(t, input, "repl", "-state", state)
+	if status != 0 {
+		t.Fatalf("repl = %d, stderr:\n%s", status, stderr)
+	}
+	for _, want := range []string{
+		"error: no track to continue",
+		`{"action":"set_track_solo","solo":true,"track":1}`,
+		`"solo": true`,
+		"error: open ",
+		"loaded " + state + ", 2 tracks",
+		"nothing to undo",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("repl output missing %q:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestRunRepl_handles(t *testing.T) {
+	input := strings.Join([]string{
+		`track(name="Bass") as bass`,
+		`track(name="Keys", index=0)`,
+		`bass.setMute(mute=true)`,
+		`:tracks`,
+	}, "\n")
+	status, stdout, stderr := runMagda(t, input, "repl", "-handles")
+	if status != 0 {
+		t.Fatalf("repl = %d, stderr:\n%s", status, stderr)
+	}
+	for _, want := range []string{
+		`{"action":"create_track","index":0,"name":"Bass","ref":"t1"}`,
+		// Keys moved Bass to track 2, and both the index and the handle follow it
+		`{"action":"set_track_mute","mute":true,"track":1,"track_ref":"t1"}`,
+		"2   \"Bass\"  false     true",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("repl output missing %q:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestRunRepl_let(t *testing.T) {
+	input := strings.Join([]string{
+		`let bars = 2`,
+		`let bass = track(name="Bass")`,
+		`bass.newClip(bar=1, length_bars=bars)`,
+		`:undo`,
+		`bass.setMute(mute=true)`,
+	}, "\n")
+	status, stdout, stderr := runMagda(t, input, "repl")
+	if status != 0 {
+		t.Fatalf("repl = %d, stderr:\n%s", status, stderr)
+	}
+	for _, want := range []string{
+		`{"action":"create_clip_at_bar","bar":1,"length_bars":2,"track":0}`,
+		// Undo replays the earlier lines, so their bindings survive
+		`{"action":"set_track_mute","mute":true,"track":0}`,
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("repl output missing %q:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "error") {
+		t.Errorf("repl reported an error:\n%s", stdout)
+	}
+}
+
+func TestSession_failedLineKeepsState(t *testing.T) {
+	s, err := newSession(newTestParser, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.eval(`track(name="Bass")`); err != nil {
+		t.Fatalf("eval() error = %v", err)
+	}
+	// The create_track succeeds in the parser, but set_mute on track 3 fails in the simulation
+	if _, err := s.eval(`track(name="Lead") track(id=3).setMute(mute=true)`); err == nil {
+		t.Fatal("eval() error = nil, want a simulation error")
+	}
+	actions, err := s.eval(`track(name="Pad")`)
+	if err != nil {
+		t.Fatalf("eval() error = %v", err)
+	}
+	if got := actions[0].Map()["index"]; got != 1 {
+		t.Errorf("Pad index = %v, want 1", got)
+	}
+	if len(s.state.Tracks) != 2 {
+		t.Errorf("state has %d tracks, want 2", len(s.state.Tracks))
+	}
+}
+
+func newTestParser() *dsl.Parser {
+	return dsl.NewParser()
+}
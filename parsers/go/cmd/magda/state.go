@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	dsl "github.com/Conceptual-Machines/magda-dsl/parsers/go"
+)
+
+// loadState reads a DAW state JSON file, wrapped in {"state": ...} or not
+func loadState(path string) (*dsl.ProjectState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state, err := dsl.DecodeState(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return state, nil
+}
+
+func runState(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("state", stderr)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	state, err := loadState(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "magda: %v\n", err)
+		return 1
+	}
+	printTracks(stdout, state)
+	return 0
+}
+
+// printTracks lists tracks with the id that track(id=N) uses to reference them
+func printTracks(w io.Writer, state *dsl.ProjectState) {
+	if len(state.Tracks) == 0 {
+		fmt.Fprintln(w, "no tracks")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSELECTED\tMUTE\tSOLO\tCLIPS\tFX")
+	for i, track := range state.Tracks {
+		fmt.Fprintf(tw, "%d\t%q\t%t\t%t\t%t\t%d\t%d\n", i+1, track.Name, track.Selected, track.Mute, track.Solo, len(track.Clips), len(track.FX))
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadState(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		wantErr string
+	}{
+		{"wrapped", `{"state": {"tracks": [{"name": "Bass"}]}}`, 1, ""},
+		{"unwrapped", `{"tracks": [{"name": "Bass"}, {"name": "Lead"}]}`, 2, ""},
+		{"no tracks", `{"state": {}}`, 0, ""},
+		{"invalid JSON", `{"tracks": [`, 0, "invalid state: "},
+		{"not an object", `[1, 2]`, 0, "invalid state: expected a JSON object"},
+		{"tracks not an array", `{"tracks": {"name": "Bass"}}`, 0, "invalid state: tracks: expected array, got object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := loadState(writeFile(t, "state.json", tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadState() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadState() error = %v", err)
+			}
+			if got := len(state.Tracks); got != tt.want {
+				t.Errorf("loadState() has %d tracks, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunState(t *testing.T) {
+	path := writeFile(t, "state.json", `{"tracks": [{"name": "Bass"}, {"name": "Lead", "selected": true}]}`)
+	status, stdout, stderr := runMagda(t, "", "state", path)
+	if status != 0 {
+		t.Fatalf("state = %d, stderr:\n%s", status, stderr)
+	}
+	want := "ID  NAME    SELECTED  MUTE   SOLO   CLIPS  FX\n" +
+		"1   \"Bass\"  false     false  false  0      0\n" +
+		"2   \"Lead\"  true      false  false  0      0\n"
+	if stdout != want {
+		t.Errorf("state stdout =\n%s\nwant\n%s", stdout, want)
+	}
+
+	if status, _, stderr := runMagda(t, "", "state", path+".missing"); status != 1 || stderr == "" {
+		t.Errorf("state on a missing file = %d, stderr %q", status, stderr)
+	}
+}
@@ -0,0 +1,90 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ISO EBNF has no character classes or escapes, so these special sequences
+// stand for characters that cannot be written as terminal strings.
+const (
+	ebnfAnyCharacter   = "? any character ?"
+	ebnfNewline        = "? newline ?"
+	ebnfTab            = "? tab ?"
+	ebnfCarriageReturn = "? carriage return ?"
+)
+
+var ebnfSyntax = &grammarSyntax{
+	comment: func(text string) string { return "(* " + text + " *)" },
+	rule:    func(name, body string) string { return name + " = " + body + " ;" },
+	ruleName: func(name string, terminal bool) string {
+		// Meta identifiers are letters and digits; spaces inside them are insignificant
+		return strings.ReplaceAll(strings.ToLower(name), "_", " ")
+	},
+	literal:  ebnfLiteral,
+	class:    ebnfClass,
+	concat:   ", ",
+	altBreak: "\n    | ",
+	brackets: true,
+}
+
+// EBNFGrammar returns the DSL grammar in ISO/IEC 14977 EBNF
+// Characters without a printable spelling are written as the special sequences
+// "? any character ?", "? newline ?", "? tab ?" and "? carriage return ?".
+func EBNFGrammar(opts GrammarOptions) string {
+	return ebnfSyntax.render(opts, []string{
+		"MAGDA DSL grammar (ISO/IEC 14977 EBNF)",
+		"Generated from the Go parser's method table by EBNFGrammar. DO NOT EDIT.",
+		"Special sequences: " + strings.Join([]string{ebnfAnyCharacter, ebnfNewline, ebnfTab, ebnfCarriageReturn}, ", "),
+	})
+}
+
+// ebnfLiteral quotes s as a terminal string; ISO EBNF strings have no escapes
+func ebnfLiteral(s string) string {
+	if special := ebnfSpecial(s); special != "" {
+		return special
+	}
+	switch {
+	case !strings.Contains(s, `"`):
+		return `"` + s + `"`
+	case !strings.Contains(s, `'`):
+		return `'` + s + `'`
+	}
+	panic(fmt.Sprintf("dsl: cannot quote %q in EBNF", s))
+}
+
+// ebnfSpecial returns the special sequence for a control character, or ""
+func ebnfSpecial(s string) string {
+	switch s {
+	case "\n":
+		return ebnfNewline
+	case "\t":
+		return ebnfTab
+	case "\r":
+		return ebnfCarriageReturn
+	}
+	return ""
+}
+
+// ebnfClass spells a character class as a choice of terminals, or as an exception
+// from any character when negated
+func ebnfClass(c gClass, nested bool) string {
+	var chars []string
+	runes := []rune(c.chars)
+	for i := 0; i < len(runes); i++ {
+		if i+2 < len(runes) && runes[i+1] == '-' {
+			for r := runes[i]; r <= runes[i+2]; r++ {
+				chars = append(chars, ebnfLiteral(string(r)))
+			}
+			i += 2
+			continue
+		}
+		chars = append(chars, ebnfLiteral(string(runes[i])))
+	}
+
+	choice := strings.Join(chars, " | ")
+	if c.negated {
+		return ebnfAnyCharacter + " - " + group(choice, len(chars) > 1)
+	}
+	return group(choice, len(chars) > 1 && nested)
+}
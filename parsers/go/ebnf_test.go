@@ -0,0 +1,277 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// readEBNF parses ISO EBNF text back into grammar rules, so tests can check what the text accepts
+// Meta identifiers are returned with their spaces removed, as ISO/IEC 14977 specifies.
+func readEBNF(src string) (map[string]grammarExpr, error) {
+	r := &ebnfReader{src: []rune(src)}
+	rules := make(map[string]grammarExpr)
+	for {
+		r.skipSpace()
+		if r.pos >= len(r.src) {
+			return rules, nil
+		}
+		name := r.identifier()
+		if name == "" {
+			return nil, r.errorf("expected meta identifier")
+		}
+		if !r.consume("=") {
+			return nil, r.errorf("expected = after %s", name)
+		}
+		expr, err := r.definitions()
+		if err != nil {
+			return nil, err
+		}
+		if !r.consume(";") {
+			return nil, r.errorf("expected ; after rule %s", name)
+		}
+		rules[name] = expr
+	}
+}
+
+type ebnfReader struct {
+	src []rune
+	pos int
+}
+
+func (r *ebnfReader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", r.pos, fmt.Sprintf(format, args...))
+}
+
+func (r *ebnfReader) skipSpace() {
+	for r.pos < len(r.src) {
+		switch {
+		case unicode.IsSpace(r.src[r.pos]):
+			r.pos++
+		case strings.HasPrefix(string(r.src[r.pos:]), "(*"):
+			end := strings.Index(string(r.src[r.pos:]), "*)")
+			if end < 0 {
+				r.pos = len(r.src)
+				return
+			}
+			r.pos += len([]rune(string(r.src[r.pos:])[:end+2]))
+		default:
+			return
+		}
+	}
+}
+
+func (r *ebnfReader) consume(s string) bool {
+	r.skipSpace()
+	if strings.HasPrefix(string(r.src[r.pos:]), s) {
+		r.pos += len([]rune(s))
+		return true
+	}
+	return false
+}
+
+// identifier reads a meta identifier, dropping the spaces inside it
+func (r *ebnfReader) identifier() string {
+	r.skipSpace()
+	if r.pos >= len(r.src) || !unicode.IsLetter(r.src[r.pos]) {
+		return ""
+	}
+	var b strings.Builder
+	for r.pos < len(r.src) {
+		c := r.src[r.pos]
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			b.WriteRune(c)
+			r.pos++
+			continue
+		}
+		// Spaces continue the identifier only if another letter or digit follows
+		next := r.pos
+		for next < len(r.src) && r.src[next] == ' ' {
+			next++
+		}
+		if next > r.pos && next < len(r.src) && (unicode.IsLetter(r.src[next]) || unicode.IsDigit(r.src[next])) {
+			r.pos = next
+			continue
+		}
+		break
+	}
+	return b.String()
+}
+
+// definitions reads a definitions list: single definitions separated by |
+func (r *ebnfReader) definitions() (grammarExpr, error) {
+	var alts gAlt
+	for {
+		def, err := r.definition()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, def)
+		if !r.consume("|") {
+			break
+		}
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return alts, nil
+}
+
+// definition reads syntactic terms separated by commas
+func (r *ebnfReader) definition() (grammarExpr, error) {
+	var seq gSeq
+	for {
+		term, err := r.term()
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, term)
+		if !r.consume(",") {
+			break
+		}
+	}
+	if len(seq) == 1 {
+		return seq[0], nil
+	}
+	return seq, nil
+}
+
+// term reads a primary with an optional "- exception"
+func (r *ebnfReader) term() (grammarExpr, error) {
+	base, err := r.primary()
+	if err != nil {
+		return nil, err
+	}
+	if !r.consume("-") {
+		return base, nil
+	}
+	except, err := r.primary()
+	if err != nil {
+		return nil, err
+	}
+	return exceptExpr{base: base, except: except}, nil
+}
+
+func (r *ebnfReader) primary() (grammarExpr, error) {
+	r.skipSpace()
+	if r.pos >= len(r.src) {
+		return nil, r.errorf("unexpected end of grammar")
+	}
+	switch c := r.src[r.pos]; {
+	case c == '"' || c == '\'':
+		r.pos++
+		end := strings.IndexRune(string(r.src[r.pos:]), c)
+		if end < 0 {
+			return nil, r.errorf("unterminated terminal string")
+		}
+		s := string(r.src[r.pos:])[:end]
+		r.pos += len([]rune(s)) + 1
+		return gLit(s), nil
+	case c == '?':
+		r.pos++
+		end := strings.IndexRune(string(r.src[r.pos:]), '?')
+		if end < 0 {
+			return nil, r.errorf("unterminated special sequence")
+		}
+		text := "? " + strings.TrimSpace(string(r.src[r.pos:])[:end]) + " ?"
+		r.pos += len([]rune(string(r.src[r.pos:])[:end])) + 1
+		switch text {
+		case ebnfAnyCharacter:
+			return gClass{negated: true}, nil
+		case ebnfNewline:
+			return gLit("\n"), nil
+		case ebnfTab:
+			return gLit("\t"), nil
+		case ebnfCarriageReturn:
+			return gLit("\r"), nil
+		}
+		return nil, r.errorf("unknown special sequence %s", text)
+	case c == '(' || c == '[' || c == '{':
+		r.pos++
+		inner, err := r.definitions()
+		if err != nil {
+			return nil, err
+		}
+		closing := map[rune]string{'(': ")", '[': "]", '{': "}"}[c]
+		if !r.consume(closing) {
+			return nil, r.errorf("expected %s", closing)
+		}
+		switch c {
+		case '[':
+			return gOpt{inner}, nil
+		case '{':
+			return gStar{inner}, nil
+		}
+		return inner, nil
+	case unicode.IsLetter(c):
+		return gRef(r.identifier()), nil
+	}
+	return nil, r.errorf("unexpected %q", r.src[r.pos])
+}
+
+func TestEBNFGrammar_acceptsSpecExamples(t *testing.T) {
+	for _, opts := range []GrammarOptions{{}, {CanonicalOnly: true}} {
+		rules, err := readEBNF(EBNFGrammar(opts))
+		if err != nil {
+			t.Fatalf("readEBNF(EBNFGrammar(%+v)) error = %v", opts, err)
+		}
+		m := newGrammarMatcher(rules)
+
+		for _, example := range specExamples(t) {
+			if opts.CanonicalOnly {
+				example = canonicalSpelling(example)
+			}
+			if !m.accepts(t, "start", example) {
+				t.Errorf("EBNFGrammar(%+v) rejects spec example:\n%s", opts, example)
+			}
+		}
+		for _, input := range grammarRejects {
+			if m.accepts(t, "start", input) {
+				t.Errorf("EBNFGrammar(%+v) accepts invalid input %q", opts, input)
+			}
+		}
+	}
+}
+
+func TestEBNFGrammar_string(t *testing.T) {
+	rules, err := readEBNF(EBNFGrammar(GrammarOptions{}))
+	if err != nil {
+		t.Fatalf("readEBNF() error = %v", err)
+	}
+	m := newGrammarMatcher(rules)
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{`"Serum"`, true},
+		{`""`, true},
+		{`"say \"hi\""`, true},
+		{`"a\\"`, true},
+		{`"open`, false},
+		{"\"line\nbreak\"", false},
+	}
+	for _, tt := range tests {
+		if got := m.accepts(t, "string", tt.input); got != tt.want {
+			t.Errorf("string accepts %q = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEBNFGrammar_isoSyntax(t *testing.T) {
+	grammar := EBNFGrammar(GrammarOptions{})
+	for _, want := range []string{
+		`track call = "track", "(", [ ws ], [ track args, [ ws ] ], ")" ;`,
+		`digit = "0" | "1" | "2" | "3" | "4" | "5" | "6" | "7" | "8" | "9" ;`,
+		`string = '"', { ? any character ? - ('"' | "\" | ? newline ?)`,
+	} {
+		if !strings.Contains(grammar, want) {
+			t.Errorf("EBNFGrammar() missing %q", want)
+		}
+	}
+	for _, op := range []string{")*", ")?", ")+", "+ ", "* ;"} {
+		if strings.Contains(grammar, op) {
+			t.Errorf("EBNFGrammar() uses the regex operator in %q", op)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError is an error at a specific location in DSL source code
+// Use errors.As to recover it from errors returned by Parse and ParseDSL.
+type ParseError struct {
+	Pos     Pos    // Start of the offending source
+	Length  int    // Length in bytes of the offending source, at least 1 when Snippet is set
+	Method  string // Offending method or call name (e.g. "setVolume", "track"), empty for syntax errors
+	Msg     string // Error message without location
+	Snippet string // Source line containing the error with a caret underline, empty if source is unavailable
+}
+
+// Error returns "line L, column C: method: message"
+func (e *ParseError) Error() string {
+	if e.Method != "" {
+		return fmt.Sprintf("line %d, column %d: %s: %s", e.Pos.Line, e.Pos.Column, e.Method, e.Msg)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+}
+
+// ParseErrors is a list of errors collected in a single pass with error recovery enabled
+// It unwraps to its elements, so errors.As finds the first *ParseError.
+type ParseErrors []*ParseError
+
+// Error returns each error on its own line
+func (e ParseErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the individual errors for errors.Is and errors.As
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// sort orders errors by source position
+func (e ParseErrors) sort() {
+	sort.SliceStable(e, func(i, j int) bool {
+		return e[i].Pos.Offset < e[j].Pos.Offset
+	})
+}
+
+// newParseError creates a ParseError without a snippet
+// The snippet is attached later by withSource, once the full source is known.
+func newParseError(pos Pos, length int, method, format string, args ...interface{}) *ParseError {
+	return &ParseError{
+		Pos:    pos,
+		Length: length,
+		Method: method,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// asParseError returns err as a *ParseError, positioning other errors at pos
+func asParseError(err error, pos Pos) *ParseError {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		return perr
+	}
+	return newParseError(pos, 1, "", "%v", err)
+}
+
+// callError creates a ParseError pointing at a chained method call
+func callError(call *MethodCall, format string, args ...interface{}) *ParseError {
+	return newParseError(call.Pos, len(call.Name)+1, call.Name, format, args...)
+}
+
+// trackError creates a ParseError pointing at a track call
+func trackError(call *TrackCall, format string, args ...interface{}) *ParseError {
+	return newParseError(call.Pos, len(call.keyword()), call.keyword(), format, args...)
+}
+
+// identError creates a ParseError pointing at a bound name
+func identError(id *Ident, format string, args ...interface{}) *ParseError {
+	return newParseError(id.Pos, len(id.Name), "", format, args...)
+}
+
+// argError creates a ParseError pointing at an argument of the named method
+func argError(method string, arg *Arg, format string, args ...interface{}) *ParseError {
+	length := len(arg.Name)
+	if length == 0 {
+		length = 1
+	}
+	return newParseError(arg.Pos, length, method, format, args...)
+}
+
+// withSource fills in the snippets of ParseErrors from the source they were produced from
+// Errors that are not ParseErrors are returned unchanged.
+func withSource(err error, src string) error {
+	var errs ParseErrors
+	if errors.As(err, &errs) {
+		for _, perr := range errs {
+			perr.attachSnippet(src)
+		}
+		return err
+	}
+
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		perr.attachSnippet(src)
+	}
+	return err
+}
+
+// attachSnippet sets the snippet if it is not already set
+func (e *ParseError) attachSnippet(src string) {
+	if e.Snippet == "" {
+		e.Snippet = snippet(src, e.Pos, e.Length)
+	}
+}
+
+// snippet returns the source line containing pos followed by a caret line underlining length bytes
+// Example:
+//
+//	track().setVolume(volume_db=loud)
+//	       ^~~~~~~~~~
+func snippet(src string, pos Pos, length int) string {
+	if pos.Offset < 0 || pos.Offset > len(src) {
+		return ""
+	}
+
+	lineStart := strings.LastIndexByte(src[:pos.Offset], '\n') + 1
+	lineEnd := len(src)
+	if i := strings.IndexByte(src[pos.Offset:], '\n'); i >= 0 {
+		lineEnd = pos.Offset + i
+	}
+	line := strings.TrimRight(src[lineStart:lineEnd], "\r")
+
+	// Pad with the same whitespace as the line so tabs stay aligned
+	var pad strings.Builder
+	for _, r := range src[lineStart:pos.Offset] {
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+
+	end := pos.Offset + length
+	if end > lineEnd {
+		end = lineEnd
+	}
+	width := utf8.RuneCountInString(src[pos.Offset:end])
+	if width < 1 {
+		width = 1
+	}
+
+	return line + "\n" + pad.String() + "^" + strings.Repeat("~", width-1)
+}
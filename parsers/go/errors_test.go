@@ -0,0 +1,191 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseError_positions(t *testing.T) {
+	tests := []struct {
+		name        string
+		dslCode     string
+		wantLine    int
+		wantColumn  int
+		wantOffset  int
+		wantMethod  string
+		wantSnippet string
+	}{
+		{
+			name:        "missing method argument",
+			dslCode:     "track(instrument=\"Serum\")\n  .newClip(bar=1)\n  .setVolume()",
+			wantLine:    3,
+			wantColumn:  3,
+			wantOffset:  46,
+			wantMethod:  "setVolume",
+			wantSnippet: "  .setVolume()\n  ^~~~~~~~~~",
+		},
+		{
+			name:        "syntax error",
+			dslCode:     `track(name="Bass").setPan(pan=0.5) oops`,
+			wantLine:    1,
+			wantColumn:  36,
+			wantOffset:  35,
+			wantSnippet: "track(name=\"Bass\").setPan(pan=0.5) oops\n                                   ^~~~",
+		},
+		{
+			name:        "unterminated string",
+			dslCode:     `track(name="Bass)`,
+			wantLine:    1,
+			wantColumn:  12,
+			wantOffset:  11,
+			wantSnippet: "track(name=\"Bass)\n           ^",
+		},
+		{
+			name:        "bad track reference",
+			dslCode:     `track(id="one").setMute(mute=true)`,
+			wantLine:    1,
+			wantColumn:  7,
+			wantOffset:  6,
+			wantMethod:  "track",
+			wantSnippet: "track(id=\"one\").setMute(mute=true)\n      ^~",
+		},
+		{
+			name:        "no selected track",
+			dslCode:     "\n\ttrack(selected=true).setMute(mute=true)",
+			wantLine:    2,
+			wantColumn:  2,
+			wantOffset:  2,
+			wantMethod:  "track",
+			wantSnippet: "\ttrack(selected=true).setMute(mute=true)\n\t^~~~~",
+		},
+		{
+			name:        "only comments",
+			dslCode:     "# just a comment",
+			wantLine:    1,
+			wantColumn:  1,
+			wantOffset:  0,
+			wantSnippet: "# just a comment\n^",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewParser().ParseDSL(tt.dslCode)
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("ParseDSL() error = %v, want *ParseError", err)
+			}
+			if perr.Pos.Line != tt.wantLine || perr.Pos.Column != tt.wantColumn || perr.Pos.Offset != tt.wantOffset {
+				t.Errorf("ParseError pos = %+v, want line %d, column %d, offset %d", perr.Pos, tt.wantLine, tt.wantColumn, tt.wantOffset)
+			}
+			if perr.Method != tt.wantMethod {
+				t.Errorf("ParseError method = %q, want %q", perr.Method, tt.wantMethod)
+			}
+			if perr.Snippet != tt.wantSnippet {
+				t.Errorf("ParseError snippet =\n%s\nwant\n%s", perr.Snippet, tt.wantSnippet)
+			}
+		})
+	}
+}
+
+func TestParseError_Error(t *testing.T) {
+	err := &ParseError{Pos: Pos{Line: 2, Column: 5}, Method: "setPan", Msg: "pan call must specify pan"}
+	want := "line 2, column 5: setPan: pan call must specify pan"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	err = &ParseError{Pos: Pos{Line: 1, Column: 1}, Msg: "empty DSL code"}
+	want = "line 1, column 1: empty DSL code"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestParseError_wrapped(t *testing.T) {
+	_, err := NewParser().ParseDSL(`track().setPan()`)
+	wrapped := fmt.Errorf("generation failed: %w", err)
+
+	var perr *ParseError
+	if !errors.As(wrapped, &perr) {
+		t.Fatalf("errors.As() did not find *ParseError in %v", wrapped)
+	}
+	if perr.Method != "setPan" {
+		t.Errorf("ParseError method = %q, want setPan", perr.Method)
+	}
+}
+
+func TestParser_errorRecovery(t *testing.T) {
+	dslCode := `track(instrument="Serum", name="Bass")
+  .newClip(length_bars=4)
+  .setVolume(volume_db=-3.0)
+  .setPan()
+track(selected=true).setMute(mute=true)
+track(name="Lead").addFX(fxname=).setSolo(solo=true)
+track(id=0).setPan(pan=2).setMute(mute=true)`
+
+	parser := NewParser()
+	parser.SetErrorRecovery(true)
+	actions, err := parser.ParseDSL(dslCode)
+
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ParseDSL() error = %v, want ParseErrors", err)
+	}
+	wantErrs := []struct {
+		line   int
+		method string
+	}{
+		{line: 2, method: "newClip"},
+		{line: 4, method: "setPan"},
+		{line: 5, method: "track"},
+		{line: 6, method: ""},
+		{line: 7, method: "track"},
+		{line: 7, method: "setPan"},
+	}
+	if len(errs) != len(wantErrs) {
+		t.Fatalf("ParseDSL() got %d errors, want %d:\n%v", len(errs), len(wantErrs), errs)
+	}
+	for i, want := range wantErrs {
+		if errs[i].Pos.Line != want.line || errs[i].Method != want.method {
+			t.Errorf("errors[%d] = %v, want line %d, method %q", i, errs[i], want.line, want.method)
+		}
+		if errs[i].Snippet == "" {
+			t.Errorf("errors[%d] has no snippet", i)
+		}
+	}
+
+	wantActions := []string{"create_track", "set_track_volume", "create_track", "set_track_solo"}
+	if len(actions) != len(wantActions) {
+		t.Fatalf("ParseDSL() got %d actions, want %d: %v", len(actions), len(wantActions), actions)
+	}
+	for i, want := range wantActions {
+		if actions[i]["action"] != want {
+			t.Errorf("actions[%d] = %v, want %s", i, actions[i]["action"], want)
+		}
+	}
+
+	// errors.As still finds the first error
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr != errs[0] {
+		t.Errorf("errors.As() = %v, want first error %v", perr, errs[0])
+	}
+}
+
+func TestParser_errorRecoveryDisabled(t *testing.T) {
+	dslCode := `track().setPan() track().setMute()`
+
+	actions, err := NewParser().ParseDSL(dslCode)
+	if actions != nil {
+		t.Errorf("ParseDSL() actions = %v, want nil", actions)
+	}
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Method != "setPan" {
+		t.Errorf("ParseDSL() error = %v, want single setPan error", err)
+	}
+	var errs ParseErrors
+	if errors.As(err, &errs) {
+		t.Errorf("ParseDSL() error = %v, want single error without recovery", err)
+	}
+}
@@ -0,0 +1,323 @@
+package dsl
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultFormatWidth is the line width past which Format puts each chained method on its own line
+const DefaultFormatWidth = 80
+
+// formatIndent indents chained methods and broken array elements
+const formatIndent = "  "
+
+// FormatOptions controls Format
+type FormatOptions struct {
+	Width int // Maximum line width; 0 means DefaultFormatWidth
+}
+
+// Format parses DSL source and prints it in canonical layout
+// Each statement starts on its own line. A chain that does not fit in the width, or that
+// has comments between its methods, gets one method per line; an array argument that still
+// does not fit gets one element per line. Methods use their canonical spellings, arguments
+// follow the order of the method's parameter table, and `=` and `,` are spaced uniformly.
+// Comments are kept: comments on their own line stay before the following call, and
+// end-of-line comments stay at the end of the line of the call they follow. Comments inside a
+// call's parentheses move to the end of that call's line.
+//
+// Example:
+//
+//	Format(`track( name = "Bass" ).setVolume(volume_db=-3)`, FormatOptions{})
+//	// track(name="Bass").set_volume(volume_db=-3)
+func Format(src string, opts FormatOptions) (string, error) {
+	prog, err := Parse(src)
+	if err != nil {
+		return "", err
+	}
+	if opts.Width <= 0 {
+		opts.Width = DefaultFormatWidth
+	}
+
+	f := &formatter{src: src, width: opts.Width}
+	f.collect(prog)
+	f.attachComments(prog.Comments)
+	return f.print(), nil
+}
+
+// formatter prints a Program, keeping comments attached to the calls around them
+type formatter struct {
+	src        string
+	width      int
+	statements [][]*formatElem
+	trailer    []*Comment // Own-line comments after the last call
+	out        strings.Builder
+}
+
+// formatElem is one call in a statement: the track call or a chained method
+type formatElem struct {
+	start, end int    // Source byte offsets
+	text       string // Canonical single-line text
+	call       *MethodCall
+	leading    []*Comment // Own-line comments before the call
+	trailing   []*Comment // End-of-line comments after the call
+}
+
+// collect normalizes every call and records its source range
+func (f *formatter) collect(prog *Program) {
+	for _, stmt := range prog.Statements {
+		var elems []*formatElem
+		if stmt.Value != nil {
+			// let bars = 8 - a single element with no chain
+			elems = append(elems, &formatElem{start: stmt.Pos.Offset, end: stmt.End, text: stmt.String()})
+			f.statements = append(f.statements, elems)
+			continue
+		}
+		prefix := ""
+		if stmt.Let {
+			prefix = letKeyword + " " + stmt.Bind.Name + " = "
+		}
+		if stmt.Track != nil {
+			if stmt.Track.Selector {
+				sortArgs(stmt.Track.Args, selectorParams)
+			} else {
+				sortArgs(stmt.Track.Args, trackParams)
+			}
+			head := &formatElem{start: stmt.Pos.Offset, end: stmt.Track.End, text: prefix + stmt.Track.String()}
+			if stmt.Bind != nil && !stmt.Let {
+				head.end = stmt.Bind.Pos.Offset + len(stmt.Bind.Name)
+				head.text += " " + asKeyword + " " + stmt.Bind.Name
+			}
+			elems = append(elems, head)
+		}
+		if stmt.Target != nil {
+			elems = append(elems, &formatElem{start: stmt.Pos.Offset, end: stmt.Target.Pos.Offset + len(stmt.Target.Name), text: prefix + stmt.Target.Name})
+		}
+		for _, call := range stmt.Chain {
+			canonicalizeCall(call)
+			elems = append(elems, &formatElem{start: call.Pos.Offset, end: call.End, text: call.String(), call: call})
+		}
+		f.statements = append(f.statements, elems)
+	}
+}
+
+// canonicalizeCall replaces an alias with the canonical method name and sorts the arguments
+func canonicalizeCall(call *MethodCall) {
+	def, ok := lookupMethod(call.Name)
+	if !ok {
+		return
+	}
+	call.Name = def.name
+	sortArgs(call.Args, def.params)
+	for _, param := range def.params {
+		if arg := call.Arg(param.name); arg != nil && len(param.fields) > 0 {
+			for _, elem := range arg.Value.Elems {
+				sortArgs(elem.Fields, param.fields)
+			}
+		}
+	}
+}
+
+// sortArgs orders args as in params; positional and unknown arguments keep their order at the end
+func sortArgs(args []*Arg, params []paramDef) {
+	rank := func(arg *Arg) int {
+		if arg.Name == "" {
+			return -1
+		}
+		for i, param := range params {
+			if param.name == arg.Name {
+				return i
+			}
+		}
+		return len(params)
+	}
+	sort.SliceStable(args, func(i, j int) bool { return rank(args[i]) < rank(args[j]) })
+}
+
+// attachComments gives each comment to the call it belongs to
+// A comment inside a call, e.g. between its arguments, trails that call. Otherwise a comment
+// alone on its line leads the next call, and any other comment trails the call before it.
+func (f *formatter) attachComments(comments []*Comment) {
+	var elems []*formatElem
+	for _, stmt := range f.statements {
+		elems = append(elems, stmt...)
+	}
+
+	if len(elems) == 0 {
+		// Only comments
+		f.trailer = comments
+		return
+	}
+
+	for _, c := range comments {
+		offset := c.Pos.Offset
+		prev := sort.Search(len(elems), func(i int) bool { return elems[i].start > offset }) - 1
+		if prev >= 0 && offset < elems[prev].end {
+			f.trail(elems, prev, c)
+			continue
+		}
+		if f.ownLine(c) {
+			next := sort.Search(len(elems), func(i int) bool { return elems[i].start > offset })
+			if next == len(elems) {
+				f.trailer = append(f.trailer, c)
+			} else {
+				elems[next].leading = append(elems[next].leading, c)
+			}
+			continue
+		}
+		if prev < 0 {
+			prev = 0
+		}
+		f.trail(elems, prev, c)
+	}
+}
+
+// trail makes c trail elems[i]
+// A line comment runs to the end of the line, so a comment after one leads the next call instead.
+func (f *formatter) trail(elems []*formatElem, i int, c *Comment) {
+	trailing := elems[i].trailing
+	if n := len(trailing); n == 0 || strings.HasPrefix(trailing[n-1].Text, "/*") {
+		elems[i].trailing = append(trailing, c)
+		return
+	}
+	if i+1 < len(elems) {
+		elems[i+1].leading = append(elems[i+1].leading, c)
+	} else {
+		f.trailer = append(f.trailer, c)
+	}
+}
+
+// ownLine reports whether only whitespace precedes c on its line
+func (f *formatter) ownLine(c *Comment) bool {
+	lineStart := strings.LastIndexByte(f.src[:c.Pos.Offset], '\n') + 1
+	return strings.TrimSpace(f.src[lineStart:c.Pos.Offset]) == ""
+}
+
+func (f *formatter) print() string {
+	prevEnd := -1
+	for _, elems := range f.statements {
+		if prevEnd >= 0 && f.blankLineBetween(prevEnd, firstOffset(elems[0])) {
+			f.out.WriteString("\n")
+		}
+		f.printStatement(elems)
+		prevEnd = lastOffset(elems[len(elems)-1])
+	}
+
+	if len(f.trailer) > 0 && prevEnd >= 0 && f.blankLineBetween(prevEnd, f.trailer[0].Pos.Offset) {
+		f.out.WriteString("\n")
+	}
+	f.printComments(f.trailer, "")
+	return f.out.String()
+}
+
+func (f *formatter) printStatement(elems []*formatElem) {
+	f.printComments(elems[0].leading, "")
+
+	if !f.needsBreak(elems) {
+		var line strings.Builder
+		for _, elem := range elems {
+			line.WriteString(elem.text)
+		}
+		f.printLine("", line.String(), elems[len(elems)-1].trailing)
+		return
+	}
+
+	for i, elem := range elems {
+		indent := formatIndent
+		if i == 0 {
+			indent = ""
+		} else {
+			f.printComments(elem.leading, indent)
+		}
+		f.printElem(elem, indent)
+	}
+}
+
+// needsBreak reports whether a statement must be printed one call per line
+func (f *formatter) needsBreak(elems []*formatElem) bool {
+	length := 0
+	for i, elem := range elems {
+		length += utf8.RuneCountInString(elem.text)
+		if (i > 0 && len(elem.leading) > 0) || (i < len(elems)-1 && len(elem.trailing) > 0) {
+			return true
+		}
+	}
+	return len(elems) > 1 && length > f.width
+}
+
+// printElem prints one call, breaking its array arguments one element per line if it is too long
+func (f *formatter) printElem(elem *formatElem, indent string) {
+	arg := breakableArg(elem.call)
+	if arg == nil || utf8.RuneCountInString(indent+elem.text) <= f.width {
+		f.printLine(indent, elem.text, elem.trailing)
+		return
+	}
+
+	// Print the call up to the opening bracket, the elements, then the rest of the call
+	open := elem.text[:strings.Index(elem.text, arg.Name+"=[")+len(arg.Name)+2]
+	f.printLine(indent, open, elem.trailing)
+	for i, value := range arg.Value.Elems {
+		sep := ","
+		if i == len(arg.Value.Elems)-1 {
+			sep = ""
+		}
+		f.printLine(indent+formatIndent, value.String()+sep, nil)
+	}
+	rest := elem.call.String()[len(open)+len(arg.Value.String())-1:]
+	f.printLine(indent, "]"+rest, nil)
+}
+
+// breakableArg returns the first named array argument with more than one element, or nil
+// Positional arrays are left on one line, since printElem finds the bracket by the argument name.
+func breakableArg(call *MethodCall) *Arg {
+	if call == nil {
+		return nil
+	}
+	for _, arg := range call.Args {
+		if arg.Name != "" && arg.Value.Kind == ArrayLiteral && len(arg.Value.Elems) > 1 {
+			return arg
+		}
+	}
+	return nil
+}
+
+func (f *formatter) printLine(indent, text string, trailing []*Comment) {
+	f.out.WriteString(indent + text)
+	for _, c := range trailing {
+		f.out.WriteString(" " + c.Text)
+	}
+	f.out.WriteString("\n")
+}
+
+func (f *formatter) printComments(comments []*Comment, indent string) {
+	for _, c := range comments {
+		f.out.WriteString(indent + c.Text + "\n")
+	}
+}
+
+// blankLineBetween reports whether the source has an empty line between two offsets
+// Only whitespace separates a statement from the next one's leading comments, so two
+// newlines mean a blank line.
+func (f *formatter) blankLineBetween(from, to int) bool {
+	if from > to {
+		return false
+	}
+	return strings.Count(f.src[from:to], "\n") > 1
+}
+
+// firstOffset is where a call's text begins, including its leading comments
+func firstOffset(elem *formatElem) int {
+	if len(elem.leading) > 0 {
+		return elem.leading[0].Pos.Offset
+	}
+	return elem.start
+}
+
+// lastOffset is where a call's text ends, including its trailing comments
+func lastOffset(elem *formatElem) int {
+	if n := len(elem.trailing); n > 0 {
+		c := elem.trailing[n-1]
+		return max(elem.end, c.Pos.Offset+len(c.Text)) // Comments inside the call end before it
+	}
+	return elem.end
+}
@@ -0,0 +1,307 @@
+package dsl
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		width int
+		want  string
+	}{
+		{
+			name: "empty",
+			src:  "  \n",
+			want: "",
+		},
+		{
+			name:  "spacing and canonical spellings",
+			src:   `track( instrument = "Serum" ,name="Bass" ) . newClip( bar = 1,length_bars=4 ).setVolume(volume_db=-3)`,
+			width: 120,
+			want:  `track(instrument="Serum", name="Bass").new_clip(bar=1, length_bars=4).set_volume(volume_db=-3)` + "\n",
+		},
+		{
+			name: "one statement per line",
+			src:  `track(id=1).set_mute(mute=true) track(id=2).set_solo(solo=true)`,
+			want: "track(id=1).set_mute(mute=true)\ntrack(id=2).set_solo(solo=true)\n",
+		},
+		{
+			name:  "argument order follows the parameter tables",
+			src:   `track(index=2, name="Bass", instrument="Serum").new_clip(length_bars=4, bar=1).add_midi(notes=[{duration=1, start=0, velocity=100, pitch=60}])`,
+			width: 160,
+			want:  `track(instrument="Serum", name="Bass", index=2).new_clip(bar=1, length_bars=4).add_midi(notes=[{pitch=60, velocity=100, start=0, duration=1}])` + "\n",
+		},
+		{
+			name: "positional track argument first",
+			src:  `track(1)`,
+			want: "track(1)\n",
+		},
+		{
+			name: "selector",
+			src:  `tracks( muted = true, 1 .. 4 ,name ~ "Drum*" ).set_mute(mute=false)`,
+			want: `tracks(1..4, name~"Drum*", muted=true).set_mute(mute=false)` + "\n",
+		},
+		{
+			name: "bound names",
+			src:  "track( name = \"Bass\" )   as   bass\nbass.addFX(fxname=\"ReaEQ\")",
+			want: "track(name=\"Bass\") as bass\nbass.add_fx(fxname=\"ReaEQ\")\n",
+		},
+		{
+			name: "let bindings",
+			src:  "let  bars=8 # phrase length\n\nlet lead =track( name = \"Lead\" ).newClip(length_bars = bars, bar = 1)\nlet solo=lead",
+			want: "let bars = 8 # phrase length\n\nlet lead = track(name=\"Lead\").new_clip(bar=1, length_bars=bars)\nlet solo = lead\n",
+		},
+		{
+			name: "multi-line chain that fits is joined",
+			src:  "track(id=1)\n  .set_mute(mute=true)\n  .set_pan(pan=0.5)",
+			want: "track(id=1).set_mute(mute=true).set_pan(pan=0.5)\n",
+		},
+		{
+			name:  "one method per line past the width",
+			src:   `track(name="Bass").set_volume(volume_db=-3).set_pan(pan=-0.25)`,
+			width: 40,
+			want:  "track(name=\"Bass\")\n  .set_volume(volume_db=-3)\n  .set_pan(pan=-0.25)\n",
+		},
+		{
+			name:  "long array one element per line",
+			src:   `track(id=1).add_midi(notes=[{pitch=60, velocity=100, start=0, duration=1}, {pitch=64, velocity=100, start=1, duration=1}])`,
+			width: 40,
+			want: `track(id=1)
+  .add_midi(notes=[
+    {pitch=60, velocity=100, start=0, duration=1},
+    {pitch=64, velocity=100, start=1, duration=1}
+  ])
+`,
+		},
+		{
+			name: "blank lines between statements kept once",
+			src:  "track(id=1)\n\n\n\ntrack(id=2)\ntrack(id=3)",
+			want: "track(id=1)\n\ntrack(id=2)\ntrack(id=3)\n",
+		},
+		{
+			name: "comments",
+			src: `# Bass line
+track(name="Bass") // create
+  # quieter
+  .set_volume(volume_db=-6)   # dB
+  .set_mute(mute=false)
+
+// done
+`,
+			want: `# Bass line
+track(name="Bass") // create
+  # quieter
+  .set_volume(volume_db=-6) # dB
+  .set_mute(mute=false)
+
+// done
+`,
+		},
+		{
+			name: "trailing comment on a joined line",
+			src:  "track(id=1)\n  .set_mute(mute=true) # mute it",
+			want: "track(id=1).set_mute(mute=true) # mute it\n",
+		},
+		{
+			name: "block comments",
+			src:  "/* intro\n   spans lines */\ntrack(id=1) /* inline */ .set_mute(mute=true)",
+			want: "/* intro\n   spans lines */\ntrack(id=1) /* inline */\n  .set_mute(mute=true)\n",
+		},
+		{
+			name: "own-line comment inside arguments",
+			src:  "track(\n# own\nname=\"B\")\ntrack(id=1).set_mute(mute=true)",
+			want: "track(name=\"B\") # own\ntrack(id=1).set_mute(mute=true)\n",
+		},
+		{
+			name: "comments inside method arguments",
+			src:  "track(id=1).set_mute(\n# note\nmute=true)\ntrack(id=2).set_pan(/* left */ pan=-1 // hard\n)",
+			want: "track(id=1).set_mute(mute=true) # note\ntrack(id=2).set_pan(pan=-1) /* left */ // hard\n",
+		},
+		{
+			name: "only comments",
+			src:  "# nothing yet\n",
+			want: "# nothing yet\n",
+		},
+		{
+			name: "only comments on one line",
+			src:  "/* a */ # b",
+			want: "/* a */\n# b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format(tt.src, FormatOptions{Width: tt.width})
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Format() =\n%s\nwant\n%s", got, tt.want)
+			}
+			again, err := Format(got, FormatOptions{Width: tt.width})
+			if err != nil {
+				t.Fatalf("Format(Format()) error = %v", err)
+			}
+			if again != got {
+				t.Errorf("Format() is not idempotent:\n%s\nthen\n%s", got, again)
+			}
+		})
+	}
+}
+
+// TestFormat_commentsAnywhere inserts comments at random token boundaries and checks that
+// Format keeps the actions, is idempotent and does not lose any comment
+func TestFormat_commentsAnywhere(t *testing.T) {
+	sources := []string{
+		`track(instrument="Serum", name="Bass").new_clip(bar=1, length_bars=4).set_volume(volume_db=-3)
+track(id=1).set_mute(mute=true)`,
+		`let bars = 8
+track(name="Lead") as lead
+lead.add_midi(notes=[{pitch=60, velocity=100, start=0, duration=1}, {pitch=62, velocity=90, start=1, duration=1}])
+lead.new_clip(bar=1, length_bars=bars)`,
+	}
+	comments := []string{"# c\n", "// c\n", "/* c */", "\n/* c\n   d */\n"}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		src := sources[rng.Intn(len(sources))]
+		// Insert from the end, so comments never land inside earlier insertions
+		boundaries := commentBoundaries(src)
+		rng.Shuffle(len(boundaries), func(i, j int) { boundaries[i], boundaries[j] = boundaries[j], boundaries[i] })
+		boundaries = boundaries[:1+rng.Intn(3)]
+		sort.Sort(sort.Reverse(sort.IntSlice(boundaries)))
+		for _, at := range boundaries {
+			src = src[:at] + comments[rng.Intn(len(comments))] + src[at:]
+		}
+
+		formatted, err := Format(src, FormatOptions{Width: 40})
+		if err != nil {
+			t.Fatalf("Format() error = %v\n%s", err, src)
+		}
+		again, err := Format(formatted, FormatOptions{Width: 40})
+		if err != nil || again != formatted {
+			t.Fatalf("Format() is not idempotent (error %v):\n%s\nthen\n%s\nsource:\n%s", err, formatted, again, src)
+		}
+		if got, want := strings.Count(formatted, " c"), strings.Count(src, " c"); got != want {
+			t.Fatalf("Format() kept %d of %d comments:\n%s\nsource:\n%s", got, want, formatted, src)
+		}
+		want, err := NewParser().ParseDSL(src)
+		if err != nil {
+			t.Fatalf("ParseDSL() error = %v\n%s", err, src)
+		}
+		got, err := NewParser().ParseDSL(formatted)
+		if err != nil {
+			t.Fatalf("ParseDSL(Format()) error = %v\n%s", err, formatted)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("ParseDSL(Format(src)) = %v\nwant %v\nformatted:\n%s", got, want, formatted)
+		}
+	}
+}
+
+// commentBoundaries returns the offsets in src where a comment can go: around brackets,
+// after commas and at line starts. The sources hold no strings containing these characters.
+func commentBoundaries(src string) []int {
+	var boundaries []int
+	for i, r := range src {
+		switch r {
+		case '(', '[', '{', ',', '\n':
+			boundaries = append(boundaries, i+1)
+		case ')', ']', '}':
+			boundaries = append(boundaries, i, i+1)
+		}
+	}
+	return boundaries
+}
+
+// TestFormat_reparses checks that Format's output parses back to the same statements
+func TestFormat_reparses(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "named array past the width",
+			src:  `track(name="Lead").add_midi(notes=[{pitch=60, velocity=100, start=0, duration=1}, {pitch=62, velocity=100, start=1, duration=1}])`,
+		},
+		{
+			name: "positional array past the width",
+			src:  `track(name="Lead").add_midi([{pitch=60, velocity=100, start=0, duration=1}, {pitch=62, velocity=100, start=1, duration=1}])`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted, err := Format(tt.src, FormatOptions{Width: 40})
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			want, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := Parse(formatted)
+			if err != nil {
+				t.Fatalf("Parse(Format()) error = %v\n%s", err, formatted)
+			}
+			if len(got.Statements) != len(want.Statements) {
+				t.Fatalf("Parse(Format()) got %d statements, want %d\n%s", len(got.Statements), len(want.Statements), formatted)
+			}
+			for i, stmt := range got.Statements {
+				if stmt.String() != want.Statements[i].String() {
+					t.Errorf("Parse(Format()) statement %d = %s, want %s", i, stmt, want.Statements[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFormat_errors(t *testing.T) {
+	_, err := Format("track(id=1).set_volume(volume_db=)", FormatOptions{})
+	if err == nil {
+		t.Fatal("Format() error = nil, want a parse error")
+	}
+	if _, ok := err.(*ParseError); !ok {
+		t.Errorf("Format() error type = %T, want *ParseError", err)
+	}
+}
+
+// TestFormat_preservesActions checks that formatting never changes what a program does
+func TestFormat_preservesActions(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		src, err := Render(randomActions(rng))
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		// Spread chains over lines and use camelCase, as LLM output often does
+		for _, method := range Methods() {
+			for _, alias := range method.Aliases {
+				src = strings.ReplaceAll(src, "."+method.Name+"(", "\n    ."+alias+"(")
+			}
+		}
+		for _, opts := range []FormatOptions{{}, {Width: 30}} {
+			formatted, err := Format(src, opts)
+			if err != nil {
+				t.Fatalf("Format() error = %v\n%s", err, src)
+			}
+			want, err := NewParser().ParseDSL(src)
+			if err != nil {
+				t.Fatalf("ParseDSL() error = %v\n%s", err, src)
+			}
+			got, err := NewParser().ParseDSL(formatted)
+			if err != nil {
+				t.Fatalf("ParseDSL(Format()) error = %v\n%s", err, formatted)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("ParseDSL(Format(src)) = %v\nwant %v\nformatted:\n%s", got, want, formatted)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package dsl
+
+import (
+	"strings"
+)
+
+var gbnfSyntax = &grammarSyntax{
+	comment: func(text string) string { return "# " + text },
+	rule:    func(name, body string) string { return name + " ::= " + body },
+	ruleName: func(name string, terminal bool) string {
+		if name == "start" {
+			return "root"
+		}
+		// GBNF rule names allow letters, digits and dashes
+		return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	},
+	literal:  escapeLiteral,
+	class:    func(c gClass, nested bool) string { return classString(c, "") },
+	concat:   " ",
+	altBreak: " |\n    ", // llama.cpp only continues a rule on the next line after "|"
+}
+
+// GBNFGrammar returns the DSL grammar in llama.cpp GBNF format
+// Like LarkGrammar it is generated from the parser's method table; the start rule is "root".
+func GBNFGrammar(opts GrammarOptions) string {
+	return gbnfSyntax.render(opts, []string{
+		"MAGDA DSL grammar (GBNF)",
+		"Generated from the Go parser's method table by GBNFGrammar. DO NOT EDIT.",
+	})
+}
@@ -0,0 +1,244 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// readGBNF parses GBNF text back into grammar rules, so tests can check what the text accepts
+func readGBNF(src string) (map[string]grammarExpr, error) {
+	r := &gbnfReader{src: []rune(src)}
+	rules := make(map[string]grammarExpr)
+	for {
+		r.skipSpace(true)
+		if r.pos >= len(r.src) {
+			return rules, nil
+		}
+		name := r.name()
+		if name == "" {
+			return nil, r.errorf("expected rule name")
+		}
+		r.skipSpace(false)
+		if !r.consume("::=") {
+			return nil, r.errorf("expected ::= after %s", name)
+		}
+		expr, err := r.alternates(false)
+		if err != nil {
+			return nil, err
+		}
+		rules[name] = expr
+	}
+}
+
+type gbnfReader struct {
+	src []rune
+	pos int
+}
+
+func (r *gbnfReader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", r.pos, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips blanks and comments; newlines only when newlineOK, as in llama.cpp
+func (r *gbnfReader) skipSpace(newlineOK bool) {
+	for r.pos < len(r.src) {
+		c := r.src[r.pos]
+		switch {
+		case c == '#':
+			for r.pos < len(r.src) && r.src[r.pos] != '\n' {
+				r.pos++
+			}
+		case c == ' ' || c == '\t' || (newlineOK && (c == '\n' || c == '\r')):
+			r.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (r *gbnfReader) consume(s string) bool {
+	if strings.HasPrefix(string(r.src[r.pos:]), s) {
+		r.pos += len([]rune(s))
+		return true
+	}
+	return false
+}
+
+func (r *gbnfReader) name() string {
+	start := r.pos
+	for r.pos < len(r.src) && (unicode.IsLetter(r.src[r.pos]) || unicode.IsDigit(r.src[r.pos]) || r.src[r.pos] == '-') {
+		r.pos++
+	}
+	return string(r.src[start:r.pos])
+}
+
+func (r *gbnfReader) alternates(nested bool) (grammarExpr, error) {
+	var alts gAlt
+	for {
+		seq, err := r.sequence(nested)
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, seq)
+		r.skipSpace(nested)
+		if !r.consume("|") {
+			break
+		}
+		r.skipSpace(true)
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return alts, nil
+}
+
+func (r *gbnfReader) sequence(nested bool) (grammarExpr, error) {
+	var seq gSeq
+	for {
+		r.skipSpace(nested)
+		if r.pos >= len(r.src) {
+			return seq, nil
+		}
+		var item grammarExpr
+		switch c := r.src[r.pos]; {
+		case c == '"':
+			r.pos++
+			s, err := r.escaped('"')
+			if err != nil {
+				return nil, err
+			}
+			item = gLit(s)
+		case c == '[':
+			r.pos++
+			negated := r.consume("^")
+			s, err := r.escaped(']')
+			if err != nil {
+				return nil, err
+			}
+			item = gClass{negated: negated, chars: s}
+		case c == '(':
+			r.pos++
+			inner, err := r.alternates(true)
+			if err != nil {
+				return nil, err
+			}
+			r.skipSpace(true)
+			if !r.consume(")") {
+				return nil, r.errorf("expected )")
+			}
+			item = inner
+		case unicode.IsLetter(c):
+			// A name followed by ::= starts the next rule
+			save := r.pos
+			name := r.name()
+			r.skipSpace(false)
+			if r.consume("::=") {
+				r.pos = save
+				return seq, nil
+			}
+			item = gRef(name)
+		default:
+			return seq, nil
+		}
+
+		switch {
+		case r.consume("?"):
+			item = gOpt{item}
+		case r.consume("*"):
+			item = gStar{item}
+		case r.consume("+"):
+			item = gPlus{item}
+		}
+		seq = append(seq, item)
+	}
+}
+
+// escaped reads up to the closing delimiter, decoding backslash escapes
+func (r *gbnfReader) escaped(end rune) (string, error) {
+	var b strings.Builder
+	for r.pos < len(r.src) {
+		c := r.src[r.pos]
+		r.pos++
+		switch c {
+		case end:
+			return b.String(), nil
+		case '\\':
+			if r.pos >= len(r.src) {
+				return "", r.errorf("unterminated escape")
+			}
+			esc := r.src[r.pos]
+			r.pos++
+			switch esc {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case 'r':
+				b.WriteRune('\r')
+			default:
+				b.WriteRune(esc)
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return "", r.errorf("unterminated %q", end)
+}
+
+func TestGBNFGrammar_acceptsSpecExamples(t *testing.T) {
+	for _, opts := range []GrammarOptions{{}, {CanonicalOnly: true}} {
+		rules, err := readGBNF(GBNFGrammar(opts))
+		if err != nil {
+			t.Fatalf("readGBNF(GBNFGrammar(%+v)) error = %v", opts, err)
+		}
+		m := newGrammarMatcher(rules)
+
+		for _, example := range specExamples(t) {
+			if opts.CanonicalOnly {
+				example = canonicalSpelling(example)
+			}
+			if !m.accepts(t, "root", example) {
+				t.Errorf("GBNFGrammar(%+v) rejects spec example:\n%s", opts, example)
+			}
+		}
+		for _, input := range grammarRejects {
+			if m.accepts(t, "root", input) {
+				t.Errorf("GBNFGrammar(%+v) accepts invalid input %q", opts, input)
+			}
+		}
+	}
+}
+
+func TestGBNFGrammar_canonicalOnlyRejectsAliases(t *testing.T) {
+	rules, err := readGBNF(GBNFGrammar(GrammarOptions{CanonicalOnly: true}))
+	if err != nil {
+		t.Fatalf("readGBNF() error = %v", err)
+	}
+	if newGrammarMatcher(rules).accepts(t, "root", `track().setVolume(volume_db=-3)`) {
+		t.Errorf("canonical-only GBNF accepts camelCase alias")
+	}
+}
+
+func TestGBNFGrammar_ruleNames(t *testing.T) {
+	grammar := GBNFGrammar(GrammarOptions{})
+	for _, want := range []string{"\nroot ::= ", "\ntrack-call ::= ", "\nset-volume-call ::= ", "\nstring ::= "} {
+		if !strings.Contains(grammar, want) {
+			t.Errorf("GBNFGrammar() missing %q", want)
+		}
+	}
+	if strings.Contains(grammar, "\n    |") {
+		t.Errorf("GBNFGrammar() starts a line with |, which llama.cpp does not accept")
+	}
+}
+
+// canonicalSpelling rewrites camelCase method aliases in src to their canonical names
+func canonicalSpelling(src string) string {
+	for _, method := range Methods() {
+		for _, alias := range method.Aliases {
+			src = strings.ReplaceAll(src, "."+alias+"(", "."+method.Name+"(")
+		}
+	}
+	return src
+}
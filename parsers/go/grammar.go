@@ -0,0 +1,412 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:generate go run ./internal/specgen -lark grammar.lark -gbnf grammar.gbnf -ebnf grammar.ebnf
+
+// GrammarOptions controls grammar generation
+type GrammarOptions struct {
+	// CanonicalOnly omits the camelCase method aliases, matching SetCanonicalMethodsOnly(true)
+	CanonicalOnly bool
+}
+
+// The grammar is built once as a small expression tree from the method table,
+// then rendered in each output format.
+
+type grammarExpr interface{}
+
+type (
+	gLit   string   // Literal text, e.g. "track"
+	gRef   string   // Reference to a rule or terminal
+	gClass struct { // Character class, e.g. [0-9] or [^"\\]
+		negated bool
+		chars   string // Characters and a-z style ranges
+	}
+	gSeq  []grammarExpr
+	gAlt  []grammarExpr
+	gOpt  struct{ expr grammarExpr }
+	gStar struct{ expr grammarExpr }
+	gPlus struct{ expr grammarExpr }
+)
+
+// grammarRule is a named rule; terminals are lexical rules such as NUMBER
+type grammarRule struct {
+	name     string
+	expr     grammarExpr
+	terminal bool
+	comment  string
+}
+
+// Terminal names
+const (
+	termString  = "STRING"
+	termNumber  = "NUMBER"
+	termInt     = "INT"
+	termBoolean = "BOOLEAN"
+	termDigit   = "DIGIT"
+	termName    = "NAME"
+	termWS      = "WS"
+	termComment = "COMMENT"
+)
+
+// buildGrammar returns the DSL grammar derived from trackParams, selectorParams and methodDefs
+// Bare chains are deliberately left out, so constrained decoding produces programs that name their track.
+func buildGrammar(opts GrammarOptions) []grammarRule {
+	rules := []grammarRule{
+		{name: "start", expr: gSeq{optWS, gPlus{gSeq{gRef("statement"), optWS}}}},
+		{
+			name: "statement",
+			expr: gAlt{
+				gSeq{gAlt{gSeq{gRef("track_call"), gOpt{gRef("binding")}}, gRef("tracks_call")}, gStar{gSeq{optWS, gRef("method_call")}}},
+				gSeq{gRef(termName), gPlus{gSeq{optWS, gRef("method_call")}}},
+				gRef("let_statement"),
+			},
+			comment: "Methods may continue on following lines; a name bound with as or let starts a chain on its track",
+		},
+		{name: "binding", expr: gSeq{optWS, gLit(asKeyword), gRef(termWS), gRef(termName)}},
+		{
+			name: "let_statement",
+			expr: gSeq{
+				gLit(letKeyword), gRef(termWS), gRef(termName), optWS, gLit("="), optWS,
+				gAlt{gSeq{gAlt{gRef("track_call"), gRef(termName)}, gStar{gSeq{optWS, gRef("method_call")}}}, gRef("let_value")},
+			},
+			comment: "let lead = track(...) binds a track, let bars = 8 a value that arguments can use by name",
+		},
+		{name: "let_value", expr: letValueAlternatives()},
+		{name: "track_call", expr: call(gLit(trackKeyword), gOpt{gRef("track_args")})},
+		{
+			name:    "track_args",
+			expr:    gAlt{gRef(termInt), commaList(gRef("track_param"))},
+			comment: "track(1) references existing track 1",
+		},
+		{name: "track_param", expr: paramAlternatives(trackParams)},
+		{name: "tracks_call", expr: call(gLit(tracksKeyword), commaList(gRef("selector_arg")))},
+		{
+			name: "selector_arg",
+			expr: gAlt{
+				gSeq{gRef(termInt), gLit(".."), gRef(termInt)},
+				gRef(termInt),
+				gLit(selectAllKeyword),
+				gRef("selector_param"),
+			},
+			comment: "Track numbers, ranges such as 1..4, all, or filters on the project state",
+		},
+		{name: "selector_param", expr: selectorAlternatives(selectorParams)},
+	}
+
+	calls := make(gAlt, len(methodDefs))
+	for i, def := range methodDefs {
+		calls[i] = gRef(def.name + "_call")
+	}
+	rules = append(rules, grammarRule{name: "method_call", expr: calls})
+
+	var elementRules []grammarRule
+	for _, def := range methodDefs {
+		names := gAlt{gLit(def.name)}
+		if !opts.CanonicalOnly {
+			for _, alias := range def.aliases {
+				names = append(names, gLit(alias))
+			}
+		}
+		var nameExpr grammarExpr = names
+		if len(names) == 1 {
+			nameExpr = names[0]
+		}
+
+		var args grammarExpr
+		var paramRule []grammarRule
+		switch {
+		case len(def.params) == 0:
+			args = nil
+		case len(def.params) == 1 && def.params[0].required:
+			args = paramExpr(def.params[0])
+		default:
+			paramRule = []grammarRule{{name: def.name + "_param", expr: paramAlternatives(def.params)}}
+			args = gOpt{commaList(gRef(def.name + "_param"))}
+		}
+		rules = append(rules, grammarRule{
+			name: def.name + "_call",
+			expr: gSeq{gLit("."), call(nameExpr, args)},
+		})
+		rules = append(rules, paramRule...)
+
+		for _, param := range def.params {
+			if len(param.fields) > 0 {
+				elementRules = append(elementRules, objectRules(param)...)
+			}
+		}
+	}
+	rules = append(rules, elementRules...)
+
+	digits := gPlus{gRef(termDigit)}
+	return append(rules,
+		grammarRule{name: termString, terminal: true, expr: gSeq{
+			gLit(`"`),
+			gStar{gAlt{gClass{negated: true, chars: "\"\\\n"}, gSeq{gLit(`\`), gClass{negated: true, chars: "\n"}}}},
+			gLit(`"`),
+		}},
+		grammarRule{name: termNumber, terminal: true, expr: gSeq{gOpt{gLit("-")}, digits, gOpt{gSeq{gLit("."), digits}}}},
+		grammarRule{name: termInt, terminal: true, expr: digits},
+		grammarRule{name: termBoolean, terminal: true, expr: gAlt{gLit(BooleanTrue), gLit("false"), gLit("True"), gLit("False")}},
+		grammarRule{name: termDigit, terminal: true, expr: gClass{chars: "0-9"}},
+		grammarRule{name: termName, terminal: true, expr: gSeq{gClass{chars: "A-Za-z_"}, gStar{gClass{chars: "A-Za-z0-9_"}}}},
+		grammarRule{name: termWS, terminal: true, expr: gPlus{gAlt{gClass{chars: " \t\r\n"}, gRef(termComment)}}},
+		grammarRule{name: termComment, terminal: true, expr: gAlt{
+			gSeq{gLit("#"), gStar{gClass{negated: true, chars: "\n"}}},
+			gSeq{gLit("//"), gStar{gClass{negated: true, chars: "\n"}}},
+			gSeq{gLit("/*"), gStar{gAlt{gClass{negated: true, chars: "*"}, gSeq{gPlus{gLit("*")}, gClass{negated: true, chars: "*/"}}}}, gPlus{gLit("*")}, gLit("/")},
+		}},
+	)
+}
+
+// optWS is optional whitespace between tokens; comments count as whitespace
+var optWS = gOpt{gRef(termWS)}
+
+// call matches name(args), with optional whitespace inside the parentheses
+// args may be nil for a call without arguments, or optional.
+func call(name, args grammarExpr) grammarExpr {
+	switch a := args.(type) {
+	case nil:
+		return gSeq{name, gLit("("), optWS, gLit(")")}
+	case gOpt:
+		return gSeq{name, gLit("("), optWS, gOpt{gSeq{a.expr, optWS}}, gLit(")")}
+	}
+	return gSeq{name, gLit("("), optWS, args, optWS, gLit(")")}
+}
+
+// commaList matches one or more items separated by commas and optional whitespace
+func commaList(item grammarExpr) grammarExpr {
+	return gSeq{item, gStar{gSeq{optWS, gLit(","), optWS, item}}}
+}
+
+// paramAlternatives matches any one name=value pair from params
+func paramAlternatives(params []paramDef) grammarExpr {
+	alts := make(gAlt, len(params))
+	for i, param := range params {
+		alts[i] = paramExpr(param)
+	}
+	return alts
+}
+
+// selectorAlternatives matches any one name=value filter from params, or name~pattern for strings
+func selectorAlternatives(params []paramDef) grammarExpr {
+	alts := paramAlternatives(params).(gAlt)
+	for _, param := range params {
+		if param.typ == ParamString {
+			alts = append(alts, gSeq{gLit(param.name), gLit("~"), gRef(termString)})
+		}
+	}
+	return alts
+}
+
+// letValueAlternatives matches a literal of any parameter type, including the array parameters of methodDefs
+func letValueAlternatives() grammarExpr {
+	alts := gAlt{gRef(termString), gRef(termNumber), gRef(termBoolean)}
+	for _, def := range methodDefs {
+		for _, param := range def.params {
+			if param.typ == ParamArray {
+				alts = append(alts, typeExpr(param))
+			}
+		}
+	}
+	return alts
+}
+
+// paramExpr matches name=value for a single parameter
+func paramExpr(param paramDef) grammarExpr {
+	return gSeq{gLit(param.name), gLit("="), valueExpr(param)}
+}
+
+// valueExpr matches a literal of the parameter's type, one of its keyword strings, or a name bound with let
+func valueExpr(param paramDef) grammarExpr {
+	alts := gAlt{typeExpr(param)}
+	for _, keyword := range param.keywords {
+		alts = append(alts, gLit(strconv.Quote(keyword)))
+	}
+	return append(alts, gRef(termName))
+}
+
+// typeExpr matches a literal of the parameter's type
+func typeExpr(param paramDef) grammarExpr {
+	switch param.typ {
+	case ParamString:
+		return gRef(termString)
+	case ParamBool:
+		return gRef(termBoolean)
+	case ParamInt:
+		if param.min < 0 {
+			return gSeq{gOpt{gLit("-")}, gRef(termInt)}
+		}
+		return gRef(termInt)
+	case ParamNumber:
+		return gRef(termNumber)
+	case ParamArray:
+		if len(param.fields) > 0 {
+			return gSeq{gLit("["), optWS, gOpt{gSeq{commaList(gRef(objectRuleName(param))), optWS}}, gLit("]")}
+		}
+	}
+	panic(fmt.Sprintf("dsl: no grammar for parameter %s", param.name))
+}
+
+// objectRules match one {field=value, ...} element of an array parameter
+func objectRules(param paramDef) []grammarRule {
+	name := objectRuleName(param)
+	return []grammarRule{
+		{name: name, expr: gSeq{gLit("{"), optWS, commaList(gRef(name + "_field")), optWS, gLit("}")}},
+		{name: name + "_field", expr: paramAlternatives(param.fields)},
+	}
+}
+
+// objectRuleName names the element rule of an array parameter, e.g. "notes" -> "note"
+func objectRuleName(param paramDef) string {
+	if param.name == "notes" {
+		return midiNoteDef
+	}
+	return strings.TrimSuffix(param.name, "s")
+}
+
+// grammarSyntax describes how one grammar format spells rules and expressions
+type grammarSyntax struct {
+	comment  func(text string) string
+	rule     func(name, body string) string
+	ruleName func(name string, terminal bool) string
+	literal  func(s string) string
+	class    func(c gClass, nested bool) string
+	concat   string // Separator between sequence items
+	altBreak string // Separator between the alternatives of a long top-level choice
+	brackets bool   // ISO style [optional] and {repeated} instead of ?, * and +
+}
+
+// render writes header and every rule of the grammar in syntax g
+func (g *grammarSyntax) render(opts GrammarOptions, header []string) string {
+	var b strings.Builder
+	for _, line := range header {
+		b.WriteString(g.comment(line) + "\n")
+	}
+	for _, rule := range buildGrammar(opts) {
+		b.WriteString("\n")
+		if rule.comment != "" {
+			b.WriteString(g.comment(rule.comment) + "\n")
+		}
+		b.WriteString(g.rule(g.ruleName(rule.name, rule.terminal), g.expr(rule.expr, false)) + "\n")
+	}
+	return b.String()
+}
+
+// expr renders e; nested marks a position where sequences and alternatives need parentheses
+func (g *grammarSyntax) expr(e grammarExpr, nested bool) string {
+	switch e := e.(type) {
+	case gLit:
+		return g.literal(string(e))
+	case gRef:
+		return g.ruleName(string(e), isTerminalName(string(e)))
+	case gClass:
+		return g.class(e, nested)
+	case gSeq:
+		var parts []string
+		for _, item := range e {
+			_, isSeq := item.(gSeq)
+			if s := g.expr(item, !isSeq); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return group(strings.Join(parts, g.concat), len(parts) > 1 && nested)
+	case gAlt:
+		parts := make([]string, len(e))
+		for i, item := range e {
+			parts[i] = g.expr(item, false)
+		}
+		if !nested && len(parts) > 3 {
+			// Long top-level alternatives get one line each
+			return strings.Join(parts, g.altBreak)
+		}
+		return group(strings.Join(parts, " | "), len(parts) > 1 && nested)
+	case gOpt:
+		if g.brackets {
+			return "[ " + g.expr(e.expr, false) + " ]"
+		}
+		return g.expr(e.expr, true) + "?"
+	case gStar:
+		if g.brackets {
+			return "{ " + g.expr(e.expr, false) + " }"
+		}
+		return g.expr(e.expr, true) + "*"
+	case gPlus:
+		if g.brackets {
+			return g.expr(gSeq{e.expr, gStar(e)}, nested)
+		}
+		return g.expr(e.expr, true) + "+"
+	}
+	panic(fmt.Sprintf("dsl: unknown grammar expression %T", e))
+}
+
+// isTerminalName reports whether a rule name is a terminal such as NUMBER
+func isTerminalName(name string) bool {
+	return name == strings.ToUpper(name)
+}
+
+// group wraps s in parentheses when needed
+func group(s string, needed bool) string {
+	if needed {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// classString renders a character class in regex syntax, escaping special characters and extra
+func classString(c gClass, extra string) string {
+	var b strings.Builder
+	b.WriteString("[")
+	if c.negated {
+		b.WriteString("^")
+	}
+	for _, r := range c.chars {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case strings.ContainsRune(`\]^`+extra, r):
+			b.WriteString(`\` + string(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// escapeLiteral quotes s with backslash escapes, as Lark and GBNF string literals use
+func escapeLiteral(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
+
+var larkSyntax = &grammarSyntax{
+	comment:  func(text string) string { return "// " + text },
+	rule:     func(name, body string) string { return name + ": " + body },
+	ruleName: func(name string, terminal bool) string { return name },
+	literal:  escapeLiteral,
+	class:    func(c gClass, nested bool) string { return "/" + classString(c, "/") + "/" },
+	concat:   " ",
+	altBreak: "\n    | ",
+}
+
+// LarkGrammar returns the DSL grammar in Lark format, for CFG-constrained decoding
+// The grammar is generated from the parser's method table, so both accept the same methods,
+// aliases and parameters. The one difference is deliberate: the parser also accepts bare
+// .method() chains on the selected track, which the grammar leaves out.
+// parsers/go/grammar.lark holds the output for the default options.
+func LarkGrammar(opts GrammarOptions) string {
+	return larkSyntax.render(opts, []string{
+		"MAGDA DSL grammar (Lark)",
+		"Generated from the Go parser's method table by LarkGrammar. DO NOT EDIT.",
+		"Regenerate with `go generate ./...` in parsers/go.",
+	})
+}
@@ -0,0 +1,306 @@
+package dsl
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGrammar_publishedFilesAreCurrent(t *testing.T) {
+	files := map[string]func(GrammarOptions) string{
+		"grammar.lark": LarkGrammar,
+		"grammar.gbnf": GBNFGrammar,
+		"grammar.ebnf": EBNFGrammar,
+	}
+	for path, generate := range files {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading %s: %v", path, err)
+		}
+		if string(got) != generate(GrammarOptions{}) {
+			t.Errorf("%s is out of date, run go generate ./...", path)
+		}
+	}
+}
+
+func TestLarkGrammar_coversMethodTable(t *testing.T) {
+	grammar := LarkGrammar(GrammarOptions{})
+	for _, method := range Methods() {
+		for _, spelling := range append([]string{method.Name}, method.Aliases...) {
+			if !strings.Contains(grammar, `"`+spelling+`"`) {
+				t.Errorf("LarkGrammar() missing method spelling %q", spelling)
+			}
+		}
+		if !strings.Contains(grammar, "\n"+method.Name+"_call: ") {
+			t.Errorf("LarkGrammar() missing rule %s_call", method.Name)
+		}
+		for _, param := range method.Params {
+			if !strings.Contains(grammar, `"`+param.Name+`" "="`) {
+				t.Errorf("LarkGrammar() missing parameter %s.%s", method.Name, param.Name)
+			}
+		}
+	}
+	for _, param := range TrackParams() {
+		if !strings.Contains(grammar, `"`+param.Name+`" "="`) {
+			t.Errorf("LarkGrammar() missing track parameter %s", param.Name)
+		}
+	}
+}
+
+func TestLarkGrammar_canonicalOnly(t *testing.T) {
+	grammar := LarkGrammar(GrammarOptions{CanonicalOnly: true})
+	for _, method := range Methods() {
+		if !strings.Contains(grammar, `"`+method.Name+`"`) {
+			t.Errorf("LarkGrammar(CanonicalOnly) missing %q", method.Name)
+		}
+		for _, alias := range method.Aliases {
+			if strings.Contains(grammar, `"`+alias+`"`) {
+				t.Errorf("LarkGrammar(CanonicalOnly) contains alias %q", alias)
+			}
+		}
+	}
+}
+
+func TestLarkGrammar_rules(t *testing.T) {
+	grammar := LarkGrammar(GrammarOptions{})
+	for _, want := range []string{
+		`set_volume_call: "." ("set_volume" | "setVolume") "(" WS? "volume_db" "=" (NUMBER | NAME) WS? ")"`,
+		`delete_call: "." "delete" "(" WS? ")"`,
+		`add_midi_call: "." ("add_midi" | "addMidi") "(" WS? "notes" "=" ("[" WS? (midi_note (WS? "," WS? midi_note)* WS?)? "]" | NAME) WS? ")"`,
+		`let_statement: "let" WS NAME WS? "=" WS? ((track_call | NAME) (WS? method_call)* | let_value)`,
+		`track_args: INT | track_param (WS? "," WS? track_param)*`,
+		`NUMBER: "-"? DIGIT+ ("." DIGIT+)?`,
+	} {
+		if !strings.Contains(grammar, want) {
+			t.Errorf("LarkGrammar() missing %q", want)
+		}
+	}
+}
+
+// specExamples returns the ```dsl code blocks from spec/examples.md
+func specExamples(t *testing.T) []string {
+	t.Helper()
+	data, err := os.ReadFile("../../spec/examples.md")
+	if err != nil {
+		t.Fatalf("reading spec/examples.md: %v", err)
+	}
+	var examples []string
+	var block []string
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		switch {
+		case !inBlock && strings.TrimSpace(line) == "```dsl":
+			inBlock, block = true, nil
+		case inBlock && strings.TrimSpace(line) == "```":
+			inBlock = false
+			examples = append(examples, strings.Join(block, "\n"))
+		case inBlock:
+			block = append(block, line)
+		}
+	}
+	if len(examples) == 0 {
+		t.Fatalf("no dsl examples found in spec/examples.md")
+	}
+	return examples
+}
+
+// grammarRejects are inputs every exported grammar must reject
+var grammarRejects = []string{
+	``,
+	`track(`,
+	`track().setVolume(volume_db="loud")`,
+	`track().newClip(bar=1.5)`,
+	`track().explode()`,
+	`.setVolume(volume_db=-3)`,
+	`track(name="Bass"`,
+	`track() /* open`,
+	`# only a comment`,
+	`track() / not a comment`,
+	`let bars 8`,
+	`let bars = tracks(all)`,
+	`track().newClip(bar=1bars)`,
+}
+
+// grammarLenient are inputs the parser accepts but the exported grammars leave out
+var grammarLenient = []string{
+	`.new_clip(bar=1)`,
+	`.delete_clip(bar=1) .set_selected(selected=false)`,
+}
+
+// exceptExpr is base minus except, used when reading ISO EBNF back
+type exceptExpr struct {
+	base, except grammarExpr
+}
+
+// grammarMatcher checks whether a rule set accepts an input
+// match returns every position at which e can end when started at pos.
+type grammarMatcher struct {
+	rules map[string]grammarExpr
+	input []rune
+	memo  map[string][]int
+}
+
+func newGrammarMatcher(rules map[string]grammarExpr) *grammarMatcher {
+	return &grammarMatcher{rules: rules}
+}
+
+// accepts reports whether the start rule matches all of input
+func (m *grammarMatcher) accepts(t *testing.T, start, input string) bool {
+	t.Helper()
+	if _, ok := m.rules[start]; !ok {
+		t.Fatalf("grammar has no rule %q", start)
+	}
+	m.input = []rune(input)
+	m.memo = make(map[string][]int)
+	for _, end := range m.match(gRef(start), 0) {
+		if end == len(m.input) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *grammarMatcher) match(e grammarExpr, pos int) []int {
+	switch e := e.(type) {
+	case gLit:
+		lit := []rune(string(e))
+		if pos+len(lit) <= len(m.input) && string(m.input[pos:pos+len(lit)]) == string(lit) {
+			return []int{pos + len(lit)}
+		}
+		return nil
+	case gRef:
+		key := fmt.Sprintf("%s@%d", e, pos)
+		if ends, ok := m.memo[key]; ok {
+			return ends
+		}
+		rule, ok := m.rules[string(e)]
+		if !ok {
+			panic(fmt.Sprintf("undefined rule %q", e))
+		}
+		m.memo[key] = nil
+		ends := m.match(rule, pos)
+		m.memo[key] = ends
+		return ends
+	case gClass:
+		if pos < len(m.input) && classContains(e, m.input[pos]) {
+			return []int{pos + 1}
+		}
+		return nil
+	case gSeq:
+		ends := []int{pos}
+		for _, item := range e {
+			var next []int
+			for _, p := range ends {
+				next = append(next, m.match(item, p)...)
+			}
+			ends = uniqueInts(next)
+		}
+		return ends
+	case gAlt:
+		var ends []int
+		for _, item := range e {
+			ends = append(ends, m.match(item, pos)...)
+		}
+		return uniqueInts(ends)
+	case gOpt:
+		return uniqueInts(append([]int{pos}, m.match(e.expr, pos)...))
+	case gStar:
+		seen := map[int]bool{pos: true}
+		ends, frontier := []int{pos}, []int{pos}
+		for len(frontier) > 0 {
+			var next []int
+			for _, p := range frontier {
+				for _, end := range m.match(e.expr, p) {
+					if !seen[end] {
+						seen[end] = true
+						next = append(next, end)
+					}
+				}
+			}
+			ends = append(ends, next...)
+			frontier = next
+		}
+		return uniqueInts(ends)
+	case gPlus:
+		return m.match(gSeq{e.expr, gStar(e)}, pos)
+	case exceptExpr:
+		excluded := map[int]bool{}
+		for _, end := range m.match(e.except, pos) {
+			excluded[end] = true
+		}
+		var ends []int
+		for _, end := range m.match(e.base, pos) {
+			if !excluded[end] {
+				ends = append(ends, end)
+			}
+		}
+		return ends
+	}
+	panic(fmt.Sprintf("unknown grammar expression %T", e))
+}
+
+func classContains(c gClass, r rune) bool {
+	chars := []rune(c.chars)
+	found := false
+	for i := 0; i < len(chars); i++ {
+		if i+2 < len(chars) && chars[i+1] == '-' {
+			found = found || (r >= chars[i] && r <= chars[i+2])
+			i += 2
+			continue
+		}
+		found = found || r == chars[i]
+	}
+	return found != c.negated
+}
+
+func uniqueInts(values []int) []int {
+	sort.Ints(values)
+	out := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func TestBuildGrammar_acceptsSpecExamples(t *testing.T) {
+	rules := make(map[string]grammarExpr)
+	for _, rule := range buildGrammar(GrammarOptions{}) {
+		rules[rule.name] = rule.expr
+	}
+	m := newGrammarMatcher(rules)
+
+	for _, example := range specExamples(t) {
+		if !m.accepts(t, "start", example) {
+			t.Errorf("grammar rejects spec example:\n%s", example)
+		}
+	}
+	for _, input := range grammarRejects {
+		if m.accepts(t, "start", input) {
+			t.Errorf("grammar accepts invalid input %q", input)
+		}
+	}
+}
+
+// TestBuildGrammar_lenientForms checks that the lenient forms stay parser-only, as documented
+func TestBuildGrammar_lenientForms(t *testing.T) {
+	rules := make(map[string]grammarExpr)
+	for _, rule := range buildGrammar(GrammarOptions{}) {
+		rules[rule.name] = rule.expr
+	}
+	m := newGrammarMatcher(rules)
+
+	for _, input := range grammarLenient {
+		parser := NewParser()
+		parser.SetState(&ProjectState{Tracks: []TrackState{{Name: "Bass", Selected: true}}})
+		if _, err := parser.ParseDSL(input); err != nil {
+			t.Errorf("ParseDSL(%q) error = %v", input, err)
+		}
+		if m.accepts(t, "start", input) {
+			t.Errorf("grammar accepts lenient input %q", input)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package dsl
+
+import (
+	"fmt"
+	"slices"
+)
+
+// binding is what a name bound with "as" or "let" refers to: a track or a value
+type binding struct {
+	index   int      // 0-based track index
+	ref     string   // Handle of the track if it was created with handles enabled, empty otherwise
+	deleted bool     // The track was deleted by a later .delete()
+	value   *Literal // Value of let name = value; nil for a track
+}
+
+// SetTrackHandles controls whether created tracks get symbolic handles
+// When enabled, each create_track carries a generated ref ("t1", "t2", ...) and every action
+// on that track repeats it as track_ref, so an executor can map handles to real DAW tracks
+// instead of trusting the predicted track index. Handles stay unique for the life of the Parser.
+func (p *Parser) SetTrackHandles(enabled bool) {
+	p.handles = enabled
+}
+
+// newHandle returns the next generated track handle
+func (p *Parser) newHandle() string {
+	p.handleCounter++
+	return fmt.Sprintf("t%d", p.handleCounter)
+}
+
+// bind binds name in the parser's scope, replacing any previous binding
+// Bindings carry over between ParseDSL calls until SetState or ResetBindings.
+func (p *Parser) bind(name string, b *binding) {
+	if p.bindings == nil {
+		p.bindings = make(map[string]*binding)
+	}
+	p.bindings[name] = b
+}
+
+// ResetBindings forgets every name bound with "as" or "let"
+// SetState calls it, since the names refer to tracks of the previous state.
+func (p *Parser) ResetBindings() {
+	p.bindings = nil
+}
+
+// bindTrack binds the statement's name to the track it applies to
+func (p *Parser) bindTrack(stmt *Statement, tracks []int, ref string) error {
+	if len(tracks) != 1 || tracks[0] < 0 {
+		return identError(stmt.Bind, "cannot bind %s to %s, it matches %d tracks", stmt.Bind.Name, stmt.Track, len(tracks))
+	}
+	p.bind(stmt.Bind.Name, &binding{index: tracks[0], ref: ref})
+	return nil
+}
+
+// lookupBinding returns the binding of a name
+// Unknown names are errors with a "did you mean" suggestion, as are names of deleted tracks.
+func (p *Parser) lookupBinding(name *Ident) (*binding, error) {
+	b, ok := p.bindings[name.Name]
+	if !ok {
+		if suggestion := p.suggestBinding(name.Name); suggestion != "" {
+			return nil, identError(name, "unknown name %q, did you mean %q?", name.Name, suggestion)
+		}
+		return nil, identError(name, "unknown name %q, bind it first with let %s = ... or track(...) as %s", name.Name, name.Name, name.Name)
+	}
+	if b.deleted {
+		return nil, identError(name, "%s refers to a deleted track", name.Name)
+	}
+	return b, nil
+}
+
+// lookupTrack returns the binding of a name used as the target of a method chain
+func (p *Parser) lookupTrack(name *Ident) (*binding, error) {
+	b, err := p.lookupBinding(name)
+	if err != nil {
+		return nil, err
+	}
+	if b.value != nil {
+		return nil, identError(name, "%s is a value, not a track", name.Name)
+	}
+	return b, nil
+}
+
+// substituteArgs replaces the names in argument values with the values bound to them
+func (p *Parser) substituteArgs(args []*Arg) error {
+	for _, arg := range args {
+		value, err := p.substitute(arg.Value)
+		if err != nil {
+			return err
+		}
+		arg.Value = value
+	}
+	return nil
+}
+
+// substitute returns lit with every name in it replaced by its bound value
+// A substituted value keeps the position of the name, so errors point at the use.
+func (p *Parser) substitute(lit *Literal) (*Literal, error) {
+	switch lit.Kind {
+	case NameLiteral:
+		name := &Ident{Pos: lit.Pos, Name: lit.Text}
+		b, err := p.lookupBinding(name)
+		if err != nil {
+			return nil, err
+		}
+		if b.value == nil {
+			return nil, identError(name, "%s is a track, not a value", name.Name)
+		}
+		value := *b.value
+		value.Pos = lit.Pos
+		return &value, nil
+	case ArrayLiteral:
+		for i, elem := range lit.Elems {
+			value, err := p.substitute(elem)
+			if err != nil {
+				return nil, err
+			}
+			lit.Elems[i] = value
+		}
+	case ObjectLiteral:
+		if err := p.substituteArgs(lit.Fields); err != nil {
+			return nil, err
+		}
+	}
+	return lit, nil
+}
+
+// suggestBinding returns the bound name closest to name, or "" if none is close
+func (p *Parser) suggestBinding(name string) string {
+	names := make([]string, 0, len(p.bindings))
+	for bound := range p.bindings {
+		names = append(names, bound)
+	}
+	slices.Sort(names) // Break ties the same way every time
+	best := ""
+	bestDist := len(name)/3 + 1 // Allow roughly one typo per three characters
+	for _, bound := range names {
+		if dist := editDistance(name, bound); dist < bestDist {
+			best, bestDist = bound, dist
+		}
+	}
+	return best
+}
+
+// deleteBoundTrack updates the bindings after the track at index is deleted
+// Names bound to it are marked deleted and later tracks move down one index.
+func (p *Parser) deleteBoundTrack(index int) {
+	for _, b := range p.bindings {
+		switch {
+		case b.value != nil:
+			// Values are not tied to a track
+		case b.index == index:
+			b.deleted = true
+		case b.index > index:
+			b.index--
+		}
+	}
+}
+
+// insertBoundTrack updates the bindings after a track is created at index
+// Tracks at or after index move up one.
+func (p *Parser) insertBoundTrack(index int) {
+	for _, b := range p.bindings {
+		if b.value == nil && b.index >= index {
+			b.index++
+		}
+	}
+}
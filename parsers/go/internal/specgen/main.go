@@ -0,0 +1,47 @@
+// Command specgen writes the spec files generated from the Go parser
+//
+// Usage (from parsers/go, normally via go generate):
+//
+//	go run ./internal/specgen -schema ../../spec/actions.schema.json
+//	go run ./internal/specgen -lark grammar.lark -gbnf grammar.gbnf -ebnf grammar.ebnf
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	dsl "github.com/Conceptual-Machines/magda-dsl/parsers/go"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "", "write the action JSON Schema to this file")
+	larkPath := flag.String("lark", "", "write the Lark grammar to this file")
+	gbnfPath := flag.String("gbnf", "", "write the GBNF grammar to this file")
+	ebnfPath := flag.String("ebnf", "", "write the ISO EBNF grammar to this file")
+	flag.Parse()
+
+	if *schemaPath != "" {
+		data, err := dsl.ActionSchemaJSON()
+		if err != nil {
+			log.Fatalf("❌ specgen: %v", err)
+		}
+		write(*schemaPath, data)
+	}
+	if *larkPath != "" {
+		write(*larkPath, []byte(dsl.LarkGrammar(dsl.GrammarOptions{})))
+	}
+	if *gbnfPath != "" {
+		write(*gbnfPath, []byte(dsl.GBNFGrammar(dsl.GrammarOptions{})))
+	}
+	if *ebnfPath != "" {
+		write(*ebnfPath, []byte(dsl.EBNFGrammar(dsl.GrammarOptions{})))
+	}
+}
+
+func write(path string, data []byte) {
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Fatalf("❌ specgen: %v", err)
+	}
+	log.Printf("✅ specgen: wrote %s", path)
+}
@@ -0,0 +1,310 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind identifies the lexical class of a token
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokLBrace
+	tokRBrace
+	tokComma
+	tokDot
+	tokAssign
+	tokRange
+	tokTilde
+)
+
+var tokenNames = map[tokenKind]string{
+	tokEOF:      "end of input",
+	tokIdent:    "identifier",
+	tokNumber:   "number",
+	tokString:   "string",
+	tokLParen:   "'('",
+	tokRParen:   "')'",
+	tokLBracket: "'['",
+	tokRBracket: "']'",
+	tokLBrace:   "'{'",
+	tokRBrace:   "'}'",
+	tokComma:    "','",
+	tokDot:      "'.'",
+	tokAssign:   "'='",
+	tokRange:    "'..'",
+	tokTilde:    "'~'",
+}
+
+// punctuation maps single-character tokens to their kinds
+var punctuation = map[rune]tokenKind{
+	'(': tokLParen,
+	')': tokRParen,
+	'[': tokLBracket,
+	']': tokRBracket,
+	'{': tokLBrace,
+	'}': tokRBrace,
+	',': tokComma,
+	'.': tokDot,
+	'=': tokAssign,
+	'~': tokTilde,
+}
+
+func (k tokenKind) String() string {
+	if name, ok := tokenNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("token(%d)", int(k))
+}
+
+// token is a single lexical token
+// For strings, text holds the unescaped contents; otherwise it is the source text.
+type token struct {
+	kind tokenKind
+	text string
+	pos  Pos
+	end  int // Byte offset just past the token
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return t.kind.String()
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	case tokIdent, tokNumber:
+		return fmt.Sprintf("%s %s", t.kind, t.text)
+	default:
+		return t.kind.String()
+	}
+}
+
+// lexer converts DSL source into tokens
+type lexer struct {
+	src      string
+	offset   int
+	line     int
+	column   int
+	comments []*Comment
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1, column: 1}
+}
+
+// tokenize returns all tokens in src, terminated by a tokEOF token, and the comments between them
+// Lexical errors are collected rather than fatal: unexpected characters are skipped,
+// and an unterminated string or block comment ends the token stream.
+func tokenize(src string) ([]token, []*Comment, ParseErrors) {
+	lx := newLexer(src)
+	var tokens []token
+	var errs ParseErrors
+	for {
+		tok, err := lx.next()
+		if err != nil {
+			errs = append(errs, err)
+			if lx.offset < len(lx.src) {
+				continue
+			}
+			tok = token{kind: tokEOF, pos: lx.pos(), end: lx.offset}
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF {
+			return tokens, lx.comments, errs
+		}
+	}
+}
+
+func (lx *lexer) pos() Pos {
+	return Pos{Offset: lx.offset, Line: lx.line, Column: lx.column}
+}
+
+// peek returns the rune at the current offset without consuming it
+func (lx *lexer) peek() rune {
+	if lx.offset >= len(lx.src) {
+		return utf8.RuneError
+	}
+	r, _ := utf8.DecodeRuneInString(lx.src[lx.offset:])
+	return r
+}
+
+// peekAt returns the byte n bytes ahead of the current offset, or 0 past the end
+func (lx *lexer) peekAt(n int) byte {
+	if lx.offset+n >= len(lx.src) {
+		return 0
+	}
+	return lx.src[lx.offset+n]
+}
+
+// advance consumes one rune and updates line/column tracking
+func (lx *lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(lx.src[lx.offset:])
+	lx.offset += size
+	if r == '\n' {
+		lx.line++
+		lx.column = 1
+	} else {
+		lx.column += size
+	}
+	return r
+}
+
+// skipSpace skips whitespace and comments, recording the comments
+// An unterminated block comment runs to the end of the source and is reported.
+func (lx *lexer) skipSpace() *ParseError {
+	for lx.offset < len(lx.src) {
+		switch {
+		case unicode.IsSpace(lx.peek()):
+			lx.advance()
+		case lx.peek() == '#' || (lx.peek() == '/' && lx.peekAt(1) == '/'):
+			lx.scanLineComment()
+		case lx.peek() == '/' && lx.peekAt(1) == '*':
+			if err := lx.scanBlockComment(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// scanLineComment scans a # or // comment up to the end of the line
+func (lx *lexer) scanLineComment() {
+	start := lx.pos()
+	for lx.offset < len(lx.src) && lx.peek() != '\n' {
+		lx.advance()
+	}
+	text := strings.TrimRight(lx.src[start.Offset:lx.offset], " \t\r")
+	lx.comments = append(lx.comments, &Comment{Pos: start, Text: text})
+}
+
+// scanBlockComment scans a /* ... */ comment, which may span lines and does not nest
+func (lx *lexer) scanBlockComment() *ParseError {
+	start := lx.pos()
+	lx.advance()
+	lx.advance()
+	for lx.offset < len(lx.src) {
+		if lx.peek() == '*' && lx.peekAt(1) == '/' {
+			lx.advance()
+			lx.advance()
+			lx.comments = append(lx.comments, &Comment{Pos: start, Text: lx.src[start.Offset:lx.offset]})
+			return nil
+		}
+		lx.advance()
+	}
+	return newParseError(start, 2, "", "unterminated block comment")
+}
+
+// next scans the next token
+func (lx *lexer) next() (token, *ParseError) {
+	if err := lx.skipSpace(); err != nil {
+		return token{}, err
+	}
+	start := lx.pos()
+	if lx.offset >= len(lx.src) {
+		return token{kind: tokEOF, pos: start, end: start.Offset}, nil
+	}
+
+	r := lx.peek()
+	switch {
+	case r == '"':
+		return lx.scanString()
+	case isDigit(r) || (r == '-' && isDigit(rune(lx.peekAt(1)))):
+		return lx.scanNumber(), nil
+	case isIdentStart(r):
+		return lx.scanIdent(), nil
+	case r == '.' && lx.peekAt(1) == '.':
+		lx.advance()
+		lx.advance()
+		return token{kind: tokRange, text: "..", pos: start, end: lx.offset}, nil
+	}
+
+	lx.advance()
+	if kind, ok := punctuation[r]; ok {
+		return token{kind: kind, text: string(r), pos: start, end: lx.offset}, nil
+	}
+
+	return token{}, newParseError(start, lx.offset-start.Offset, "", "unexpected character %q", r)
+}
+
+// scanString scans a double-quoted string literal, handling backslash escapes
+func (lx *lexer) scanString() (token, *ParseError) {
+	start := lx.pos()
+	lx.advance() // opening quote
+
+	var sb strings.Builder
+	for lx.offset < len(lx.src) {
+		r := lx.advance()
+		switch r {
+		case '"':
+			return token{kind: tokString, text: sb.String(), pos: start, end: lx.offset}, nil
+		case '\\':
+			if lx.offset >= len(lx.src) {
+				break
+			}
+			switch esc := lx.advance(); esc {
+			case 'n':
+				sb.WriteRune('\n')
+			case 't':
+				sb.WriteRune('\t')
+			default:
+				// \" and \\ map to themselves, unknown escapes are kept verbatim
+				sb.WriteRune(esc)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return token{}, newParseError(start, 1, "", "unterminated string")
+}
+
+// scanNumber scans -?\d+(\.\d+)?
+// A dot is only consumed when followed by a digit, so "1.newClip" does not lex as a float.
+func (lx *lexer) scanNumber() token {
+	start := lx.pos()
+	if lx.peek() == '-' {
+		lx.advance()
+	}
+	for isDigit(lx.peek()) {
+		lx.advance()
+	}
+	if lx.peek() == '.' && isDigit(rune(lx.peekAt(1))) {
+		lx.advance()
+		for isDigit(lx.peek()) {
+			lx.advance()
+		}
+	}
+	return token{kind: tokNumber, text: lx.src[start.Offset:lx.offset], pos: start, end: lx.offset}
+}
+
+func (lx *lexer) scanIdent() token {
+	start := lx.pos()
+	for lx.offset < len(lx.src) && isIdentPart(lx.peek()) {
+		lx.advance()
+	}
+	return token{kind: tokIdent, text: lx.src[start.Offset:lx.offset], pos: start, end: lx.offset}
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || isDigit(r)
+}
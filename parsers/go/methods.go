@@ -0,0 +1,188 @@
+package dsl
+
+import (
+	"strings"
+)
+
+// methodDef describes a chainable method such as .new_clip(...)
+type methodDef struct {
+	name      string     // Canonical spelling from spec/grammar.md, e.g. "new_clip"
+	aliases   []string   // Alternative spellings accepted unless canonical-only mode is enabled
+	params    []paramDef // Keyword parameters; arguments are validated against these before translation
+	translate func(p *Parser, call *MethodCall, trackIndex int) (Action, error)
+}
+
+// methodDefs is the canonical method table, in spec/grammar.md order
+// Constraints across parameters (e.g. "bar or start") are checked by the translators.
+var methodDefs = []*methodDef{
+	{
+		name:    "new_clip",
+		aliases: []string{"newClip"},
+		params: []paramDef{
+			intParam("bar", 1, noMax),
+			numberParam("start", 0, noMax),
+			intParam("length_bars", 1, noMax),
+			numberParam("length", 0, noMax),
+			numberParam("position", 0, noMax),
+		},
+		translate: (*Parser).parseClipCall,
+	},
+	{
+		name:      "add_midi",
+		aliases:   []string{"addMidi"},
+		params:    []paramDef{requiredParam(objectArrayParam("notes", noteParams))},
+		translate: (*Parser).parseMidiCall,
+	},
+	{
+		name:      "add_fx",
+		aliases:   []string{"addFX", "addInstrument"},
+		params:    []paramDef{stringParam("fxname"), stringParam("instrument")},
+		translate: (*Parser).parseFXCall,
+	},
+	{
+		name:      "set_volume",
+		aliases:   []string{"setVolume"},
+		params:    []paramDef{requiredParam(numberParam("volume_db", MinVolumeDB, MaxVolumeDB))},
+		translate: (*Parser).parseVolumeCall,
+	},
+	{
+		name:      "set_pan",
+		aliases:   []string{"setPan"},
+		params:    []paramDef{requiredParam(numberParam("pan", MinPan, MaxPan))},
+		translate: (*Parser).parsePanCall,
+	},
+	{
+		name:      "set_mute",
+		aliases:   []string{"setMute"},
+		params:    []paramDef{requiredParam(boolParam("mute"))},
+		translate: (*Parser).parseMuteCall,
+	},
+	{
+		name:      "set_solo",
+		aliases:   []string{"setSolo"},
+		params:    []paramDef{requiredParam(boolParam("solo"))},
+		translate: (*Parser).parseSoloCall,
+	},
+	{
+		name:      "set_name",
+		aliases:   []string{"setName"},
+		params:    []paramDef{requiredParam(stringParam("name"))},
+		translate: (*Parser).parseNameCall,
+	},
+	{
+		name:      "set_selected",
+		aliases:   []string{"setSelected"},
+		params:    []paramDef{requiredParam(boolParam("selected"))},
+		translate: (*Parser).parseSelectedCall,
+	},
+	{
+		name:      "delete",
+		translate: (*Parser).parseDeleteCall,
+	},
+	{
+		name:    "delete_clip",
+		aliases: []string{"deleteClip"},
+		params: []paramDef{
+			intParam("clip", 0, noMax),
+			intParam("bar", 1, noMax),
+			numberParam("position", 0, noMax),
+		},
+		translate: (*Parser).parseDeleteClipCall,
+	},
+}
+
+// MethodSpec describes a chainable method, for tools that generate grammars or documentation
+type MethodSpec struct {
+	Name    string   // Canonical snake_case spelling, e.g. "new_clip"
+	Aliases []string // Alternative spellings, e.g. "newClip"
+	Params  []ParamSpec
+}
+
+// Methods returns every chainable method, in spec/grammar.md order
+// The result is a copy; changing it does not affect the parser.
+func Methods() []MethodSpec {
+	specs := make([]MethodSpec, len(methodDefs))
+	for i, def := range methodDefs {
+		specs[i] = MethodSpec{
+			Name:    def.name,
+			Aliases: append([]string(nil), def.aliases...),
+			Params:  paramSpecs(def.params),
+		}
+	}
+	return specs
+}
+
+// methodsByName indexes methodDefs by canonical name and every alias
+var methodsByName = indexMethods(methodDefs)
+
+func indexMethods(defs []*methodDef) map[string]*methodDef {
+	index := make(map[string]*methodDef)
+	for _, def := range defs {
+		index[def.name] = def
+		for _, alias := range def.aliases {
+			index[alias] = def
+		}
+	}
+	return index
+}
+
+// lookupMethod returns the definition for a method spelling, canonical or alias
+func lookupMethod(name string) (*methodDef, bool) {
+	def, ok := methodsByName[name]
+	return def, ok
+}
+
+// suggestMethod returns the known method spelling closest to name, or "" if none is close
+// Names are compared case-insensitively with underscores removed, so "setvolum" and "set_volum"
+// both match. Ties prefer the spelling in the same style (snake_case or camelCase) as name.
+// With canonicalOnly, only canonical names are suggested.
+func suggestMethod(name string, canonicalOnly bool) string {
+	target := normalizeMethodName(name)
+	best := ""
+	bestDist := len(target)/3 + 1 // Allow roughly one typo per three characters
+	for _, def := range methodDefs {
+		spellings := []string{def.name}
+		if !canonicalOnly {
+			spellings = append(spellings, def.aliases...)
+		}
+		for _, spelling := range spellings {
+			dist := editDistance(target, normalizeMethodName(spelling))
+			if dist < bestDist || (dist == bestDist && best != "" && !sameStyle(name, best) && sameStyle(name, spelling)) {
+				best, bestDist = spelling, dist
+			}
+		}
+	}
+	return best
+}
+
+// normalizeMethodName lowercases name and strips underscores
+func normalizeMethodName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// sameStyle reports whether two method names are both snake_case or both camelCase
+func sameStyle(a, b string) bool {
+	return strings.Contains(a, "_") == strings.Contains(b, "_")
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,91 @@
+package dsl
+
+import (
+	"testing"
+)
+
+func TestLookupMethod(t *testing.T) {
+	for _, def := range methodDefs {
+		for _, spelling := range append([]string{def.name}, def.aliases...) {
+			got, ok := lookupMethod(spelling)
+			if !ok || got != def {
+				t.Errorf("lookupMethod(%q) = %v, want %s", spelling, got, def.name)
+			}
+		}
+	}
+
+	if _, ok := lookupMethod("setVolum"); ok {
+		t.Errorf("lookupMethod(setVolum) found a method")
+	}
+}
+
+func TestSuggestMethod(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		canonicalOnly bool
+		want          string
+	}{
+		{name: "camelCase typo", input: "setVolum", want: "setVolume"},
+		{name: "snake_case typo", input: "set_volum", want: "set_volume"},
+		{name: "wrong case", input: "SetPan", want: "setPan"},
+		{name: "missing underscore", input: "newclip", want: "newClip"},
+		{name: "transposed letters", input: "addMdii", want: "addMidi"},
+		{name: "canonical only", input: "setVolum", canonicalOnly: true, want: "set_volume"},
+		{name: "delete typo", input: "delet", want: "delete"},
+		{name: "nothing close", input: "transpose", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suggestMethod(tt.input, tt.canonicalOnly); got != tt.want {
+				t.Errorf("suggestMethod(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"setvolume", "setvolume", 0},
+		{"setvolum", "setvolume", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	specs := Methods()
+	if len(specs) != len(methodDefs) {
+		t.Fatalf("len(Methods()) = %d, want %d", len(specs), len(methodDefs))
+	}
+
+	volume := specs[3]
+	if volume.Name != "set_volume" || len(volume.Aliases) != 1 || volume.Aliases[0] != "setVolume" {
+		t.Errorf("Methods()[3] = %+v, want set_volume with alias setVolume", volume)
+	}
+	if p := volume.Params[0]; p.Name != "volume_db" || p.Type != ParamNumber || !p.Required || p.Min != MinVolumeDB || p.Max != MaxVolumeDB {
+		t.Errorf("set_volume param = %+v", p)
+	}
+
+	notes := specs[1].Params[0]
+	if notes.Type != ParamArray || len(notes.Fields) != 5 || notes.Fields[0].Name != "pitch" || notes.Fields[0].Max != MaxMidiValue {
+		t.Errorf("add_midi notes param = %+v", notes)
+	}
+
+	// The result is a copy
+	specs[3].Aliases[0] = "changed"
+	if methodDefs[3].aliases[0] != "setVolume" {
+		t.Errorf("modifying Methods() changed the method table")
+	}
+}
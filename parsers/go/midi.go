@@ -0,0 +1,81 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxMidiValue is the largest valid MIDI pitch or velocity
+	MaxMidiValue = 127
+	// MaxMidiChannel is the largest valid MIDI channel (channels are 0-based)
+	MaxMidiChannel = 15
+)
+
+// MidiNote is a single note parsed from .addMidi(notes=[...])
+// Start and Duration are in beats relative to the start of the clip.
+type MidiNote struct {
+	Pitch    int     `json:"pitch" schema:"minimum=0,maximum=127"`
+	Velocity int     `json:"velocity" schema:"minimum=0,maximum=127"`
+	Start    float64 `json:"start" schema:"minimum=0"`
+	Duration float64 `json:"duration" schema:"minimum=0"`
+	Channel  int     `json:"channel,omitempty" schema:"minimum=0,maximum=15"` // Optional, defaults to 0
+}
+
+// noteParams describes the fields of a MIDI note object, for Methods and grammar generation
+var noteParams = []paramDef{
+	requiredParam(intParam("pitch", 0, MaxMidiValue)),
+	requiredParam(intParam("velocity", 0, MaxMidiValue)),
+	requiredParam(numberParam("start", 0, noMax)),
+	requiredParam(numberParam("duration", 0, noMax)),
+	intParam("channel", 0, MaxMidiChannel),
+}
+
+// parseNotes converts a notes=[{pitch=60, velocity=100, start=0, duration=1}, ...] argument to MidiNotes
+func parseNotes(call *MethodCall, arg *Arg) ([]MidiNote, error) {
+	if arg.Value.Kind != ArrayLiteral {
+		return nil, argError(call.Name, arg, "notes must be an array, got %s", arg.Value.Kind)
+	}
+
+	notes := make([]MidiNote, 0, len(arg.Value.Elems))
+	for i, elem := range arg.Value.Elems {
+		note, err := parseNote(call, i, elem)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
+
+// parseNote converts one {pitch=60, velocity=100, start=0, duration=1} object to a MidiNote
+// The fields are validated against noteParams, so errors read the same as for method arguments.
+func parseNote(call *MethodCall, index int, lit *Literal) (MidiNote, error) {
+	var note MidiNote
+	if lit.Kind != ObjectLiteral {
+		return note, newParseError(lit.Pos, 1, call.Name, "notes[%d] must be an object, got %s", index, lit.Kind)
+	}
+	if err := validateArgs(call.Name, lit.Pos, lit.Fields, noteParams); err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			perr.Msg = fmt.Sprintf("notes[%d]: %s", index, perr.Msg)
+		}
+		return note, err
+	}
+
+	for _, field := range lit.Fields {
+		switch field.Name {
+		case "pitch":
+			note.Pitch, _ = field.Value.Int()
+		case "velocity":
+			note.Velocity, _ = field.Value.Int()
+		case "channel":
+			note.Channel, _ = field.Value.Int()
+		case "start":
+			note.Start, _ = field.Value.Float()
+		case "duration":
+			note.Duration, _ = field.Value.Float()
+		}
+	}
+	return note, nil
+}
@@ -0,0 +1,300 @@
+package dsl
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MinVolumeDB is the lowest accepted track volume in dB (effectively silence)
+	MinVolumeDB = -150.0
+	// MaxVolumeDB is the highest accepted track volume in dB
+	MaxVolumeDB = 24.0
+	// MinPan is hard left
+	MinPan = -1.0
+	// MaxPan is hard right
+	MaxPan = 1.0
+)
+
+// ParamType is the literal type a parameter accepts
+type ParamType int
+
+// Parameter types
+const (
+	ParamString ParamType = iota
+	ParamInt
+	ParamNumber
+	ParamBool
+	ParamArray
+)
+
+func (t ParamType) String() string {
+	switch t {
+	case ParamString:
+		return "a string"
+	case ParamInt:
+		return "an integer"
+	case ParamNumber:
+		return "a number"
+	case ParamBool:
+		return "a boolean"
+	case ParamArray:
+		return "an array"
+	default:
+		return "ParamType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// paramDef describes one keyword parameter of a method or track call
+// Numeric parameters are checked against [min, max]; use math.Inf for an open bound.
+type paramDef struct {
+	name     string
+	typ      ParamType
+	required bool
+	min      float64
+	max      float64
+	fields   []paramDef // Fields of the objects in an array parameter, e.g. MIDI notes
+	keywords []string   // Strings accepted in place of a value of typ, e.g. "first"
+}
+
+// Parameter constructors keep the method table readable
+
+func stringParam(name string) paramDef {
+	return paramDef{name: name, typ: ParamString}
+}
+
+func boolParam(name string) paramDef {
+	return paramDef{name: name, typ: ParamBool}
+}
+
+// objectArrayParam is an array of objects whose fields are described by fields
+func objectArrayParam(name string, fields []paramDef) paramDef {
+	return paramDef{name: name, typ: ParamArray, fields: fields}
+}
+
+func intParam(name string, min, max float64) paramDef {
+	return paramDef{name: name, typ: ParamInt, min: min, max: max}
+}
+
+func numberParam(name string, min, max float64) paramDef {
+	return paramDef{name: name, typ: ParamNumber, min: min, max: max}
+}
+
+// withKeywords also accepts the given strings as values of a parameter
+func withKeywords(def paramDef, keywords ...string) paramDef {
+	def.keywords = keywords
+	return def
+}
+
+// requiredParam marks a parameter as required
+func requiredParam(def paramDef) paramDef {
+	def.required = true
+	return def
+}
+
+// noMax is the upper bound of numeric parameters without a maximum
+var noMax = math.Inf(1)
+
+// selectedFirst is the track(selected=...) keyword that references only the first selected track
+const selectedFirst = "first"
+
+// trackParams is the schema for keyword arguments of track(...)
+// A single positional integer, as in track(1), is validated separately.
+var trackParams = []paramDef{
+	stringParam("instrument"),
+	stringParam("name"),
+	intParam("index", 0, noMax),
+	intParam("id", 1, noMax),
+	withKeywords(boolParam("selected"), selectedFirst),
+	stringParam("ref"),
+}
+
+// ParamSpec describes a keyword parameter of a method or track call
+type ParamSpec struct {
+	Name     string
+	Type     ParamType
+	Required bool
+	Min      float64     // Lower bound of numeric parameters, or -Inf
+	Max      float64     // Upper bound of numeric parameters, or +Inf
+	Fields   []ParamSpec // Fields of the objects in an array parameter, e.g. MIDI notes
+	Keywords []string    // Strings accepted in place of a value of Type, e.g. "first"
+}
+
+// TrackParams returns the keyword parameters accepted by track(...)
+func TrackParams() []ParamSpec {
+	return paramSpecs(trackParams)
+}
+
+func paramSpecs(defs []paramDef) []ParamSpec {
+	if len(defs) == 0 {
+		return nil
+	}
+	specs := make([]ParamSpec, len(defs))
+	for i, def := range defs {
+		specs[i] = ParamSpec{
+			Name:     def.name,
+			Type:     def.typ,
+			Required: def.required,
+			Min:      def.min,
+			Max:      def.max,
+			Fields:   paramSpecs(def.fields),
+			Keywords: append([]string(nil), def.keywords...),
+		}
+		if def.typ != ParamInt && def.typ != ParamNumber {
+			specs[i].Min, specs[i].Max = math.Inf(-1), math.Inf(1)
+		}
+	}
+	return specs
+}
+
+// validateArgs checks args against a parameter schema
+// It reports positional arguments, duplicate and unknown keys, wrong types,
+// out-of-range values and missing required parameters.
+func validateArgs(method string, pos Pos, args []*Arg, params []paramDef) error {
+	seen := make(map[string]bool, len(args))
+	for _, arg := range args {
+		if arg.Name == "" {
+			return argError(method, arg, "positional arguments are not supported, use name=value")
+		}
+		if seen[arg.Name] {
+			return argError(method, arg, "duplicate parameter %q", arg.Name)
+		}
+		seen[arg.Name] = true
+
+		def, ok := findParam(params, arg.Name)
+		if !ok {
+			if len(params) == 0 {
+				return argError(method, arg, "unknown parameter %q, %s takes no parameters", arg.Name, method)
+			}
+			return argError(method, arg, "unknown parameter %q, expected one of %s", arg.Name, paramNames(params))
+		}
+		if err := def.check(method, arg); err != nil {
+			return err
+		}
+	}
+
+	for _, def := range params {
+		if def.required && !seen[def.name] {
+			return newParseError(pos, len(method)+1, method, "missing required parameter %q", def.name)
+		}
+	}
+	return nil
+}
+
+// check validates the type and range of a single argument
+func (d paramDef) check(method string, arg *Arg) error {
+	value := arg.Value
+	if len(d.keywords) > 0 && value.Kind == StringLiteral {
+		if slices.Contains(d.keywords, value.Text) {
+			return nil
+		}
+		return argError(method, arg, "%s must be %s or one of %s, got %s", d.name, d.typ, quoteKeywords(d.keywords), value.describe())
+	}
+	switch d.typ {
+	case ParamString:
+		if value.Kind != StringLiteral {
+			return argError(method, arg, "%s must be %s, got %s", d.name, d.typ, value.Kind)
+		}
+	case ParamBool:
+		if value.Kind != BoolLiteral {
+			return argError(method, arg, "%s must be %s, got %s", d.name, d.typ, value.Kind)
+		}
+	case ParamArray:
+		if value.Kind != ArrayLiteral {
+			return argError(method, arg, "%s must be %s, got %s", d.name, d.typ, value.Kind)
+		}
+	case ParamInt, ParamNumber:
+		if value.Kind != NumberLiteral {
+			return argError(method, arg, "%s must be %s, got %s", d.name, d.typ, value.Kind)
+		}
+		n, ok := value.Float()
+		if !ok {
+			return argError(method, arg, "%s: invalid number %q", d.name, value.Text)
+		}
+		if _, isInt := value.Int(); d.typ == ParamInt && !isInt {
+			return argError(method, arg, "%s must be %s, got %s", d.name, d.typ, value.describe())
+		}
+		if n < d.min || n > d.max {
+			return argError(method, arg, "%s %s out of range %s", d.name, value.Text, d.rangeString())
+		}
+	}
+	return nil
+}
+
+// rangeString describes the accepted range, e.g. "-1 to 1" or ">= 0"
+func (d paramDef) rangeString() string {
+	format := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	switch {
+	case math.IsInf(d.min, -1):
+		return "<= " + format(d.max)
+	case math.IsInf(d.max, 1):
+		return ">= " + format(d.min)
+	default:
+		return format(d.min) + " to " + format(d.max)
+	}
+}
+
+// quoteKeywords lists keyword values for error messages, e.g. `"first"`
+func quoteKeywords(keywords []string) string {
+	quoted := make([]string, len(keywords))
+	for i, keyword := range keywords {
+		quoted[i] = strconv.Quote(keyword)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func findParam(params []paramDef, name string) (paramDef, bool) {
+	for _, def := range params {
+		if def.name == name {
+			return def, true
+		}
+	}
+	return paramDef{}, false
+}
+
+// paramNames lists parameter names for error messages, e.g. "bar, length_bars"
+func paramNames(params []paramDef) string {
+	names := make([]string, len(params))
+	for i, def := range params {
+		names[i] = def.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// validateTrackArgs checks the arguments of a track(...) call or tracks(...) selector
+// track(N) with a single positional integer is a reference; otherwise all arguments are keywords.
+func validateTrackArgs(call *TrackCall) error {
+	if call.Selector {
+		return validateSelectorArgs(call)
+	}
+	if len(call.Args) == 1 && call.Args[0].Name == "" {
+		arg := call.Args[0]
+		if n, ok := arg.Value.Int(); !ok || n < 1 {
+			return argError(trackKeyword, arg, "track reference must be a positive integer, got %s", arg.Value.describe())
+		}
+		return nil
+	}
+	if err := validateArgs(trackKeyword, call.Pos, call.Args, trackParams); err != nil {
+		return err
+	}
+
+	// Reference forms select an existing track, so creation arguments would be ignored
+	for _, key := range []string{"id", "ref", "selected"} {
+		if call.Arg(key) == nil {
+			continue
+		}
+		for _, arg := range call.Args {
+			if slices.Contains(trackCreateKeys, arg.Name) {
+				return argError(trackKeyword, arg, "%s cannot be combined with %s, which references an existing track instead of creating one", arg.Name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// trackCreateKeys are the track(...) parameters that only apply when a track is created
+var trackCreateKeys = []string{"instrument", "name", "index"}
@@ -0,0 +1,192 @@
+package dsl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		wantMsg string // Empty means no error
+	}{
+		{
+			name:    "valid volume",
+			dslCode: `track().setVolume(volume_db=-3.0)`,
+		},
+		{
+			name:    "unparseable volume",
+			dslCode: `track().setVolume(volume_db="loud")`,
+			wantMsg: "volume_db must be a number, got string",
+		},
+		{
+			name:    "volume too loud",
+			dslCode: `track().setVolume(volume_db=30)`,
+			wantMsg: "volume_db 30 out of range -150 to 24",
+		},
+		{
+			name:    "pan out of range",
+			dslCode: `track().setPan(pan=1.5)`,
+			wantMsg: "pan 1.5 out of range -1 to 1",
+		},
+		{
+			name:    "pan hard left",
+			dslCode: `track().setPan(pan=-1)`,
+		},
+		{
+			name:    "mute not boolean",
+			dslCode: `track().setMute(mute="yes")`,
+			wantMsg: "mute must be a boolean, got string",
+		},
+		{
+			name:    "fractional bar",
+			dslCode: `track().newClip(bar=1.5)`,
+			wantMsg: "bar must be an integer, got 1.5",
+		},
+		{
+			name:    "bar zero",
+			dslCode: `track().newClip(bar=0)`,
+			wantMsg: "bar 0 out of range >= 1",
+		},
+		{
+			name:    "negative clip start",
+			dslCode: `track().newClip(start=-2)`,
+			wantMsg: "start -2 out of range >= 0",
+		},
+		{
+			name:    "unknown clip key",
+			dslCode: `track().newClip(bar=1, bars=4)`,
+			wantMsg: `unknown parameter "bars", expected one of bar, start, length_bars, length, position`,
+		},
+		{
+			name:    "parameter on delete",
+			dslCode: `track(1).delete(force=true)`,
+			wantMsg: `unknown parameter "force", delete takes no parameters`,
+		},
+		{
+			name:    "duplicate key",
+			dslCode: `track().setPan(pan=0.1, pan=0.2)`,
+			wantMsg: `duplicate parameter "pan"`,
+		},
+		{
+			name:    "positional method argument",
+			dslCode: `track().setPan(0.5)`,
+			wantMsg: "positional arguments are not supported, use name=value",
+		},
+		{
+			name:    "missing required",
+			dslCode: `track().setName()`,
+			wantMsg: `missing required parameter "name"`,
+		},
+		{
+			name:    "clip bar with start",
+			dslCode: `track().newClip(bar=1, start=2)`,
+			wantMsg: "clip call cannot mix bar with start, use bar and length_bars or start and length",
+		},
+		{
+			name:    "clip start with length_bars",
+			dslCode: `track().newClip(start=1, length_bars=4)`,
+			wantMsg: "clip call cannot mix length_bars with start, use bar and length_bars or start and length",
+		},
+		{
+			name:    "clip start and position",
+			dslCode: `track().newClip(start=1, position=2)`,
+			wantMsg: "clip call must specify only one of start or position",
+		},
+		{
+			name:    "fxname and instrument",
+			dslCode: `track().addFX(fxname="ReaEQ", instrument="Serum")`,
+			wantMsg: "FX call must specify only one of fxname or instrument",
+		},
+		{
+			name:    "note field out of range",
+			dslCode: `track().addMidi(notes=[{pitch=60, velocity=100, start=0, duration=1}, {pitch=128, velocity=100, start=0, duration=1}])`,
+			wantMsg: "notes[1]: pitch 128 out of range 0 to 127",
+		},
+		{
+			name:    "duplicate note field",
+			dslCode: `track().addMidi(notes=[{pitch=60, pitch=62, velocity=100, start=0, duration=1}])`,
+			wantMsg: `notes[0]: duplicate parameter "pitch"`,
+		},
+		{
+			name:    "missing note field",
+			dslCode: `track().addMidi(notes=[{pitch=60, velocity=100, start=0}])`,
+			wantMsg: `notes[0]: missing required parameter "duration"`,
+		},
+		{
+			name:    "notes not an array",
+			dslCode: `track().addMidi(notes="C4")`,
+			wantMsg: "notes must be an array, got string",
+		},
+		{
+			name:    "unknown track key",
+			dslCode: `track(instrument="Serum", plugin="ReaEQ")`,
+			wantMsg: `unknown parameter "plugin", expected one of instrument, name, index, id, selected, ref`,
+		},
+		{
+			name:    "track name not a string",
+			dslCode: `track(name=5)`,
+			wantMsg: "name must be a string, got number",
+		},
+		{
+			name:    "track id zero",
+			dslCode: `track(id=0).setMute(mute=true)`,
+			wantMsg: "id 0 out of range >= 1",
+		},
+		{
+			name:    "track id with name",
+			dslCode: `track(id=1, name="Bass").setMute(mute=true)`,
+			wantMsg: "name cannot be combined with id, which references an existing track instead of creating one",
+		},
+		{
+			name:    "track selected with instrument",
+			dslCode: `track(instrument="Serum", selected=true)`,
+			wantMsg: "instrument cannot be combined with selected, which references an existing track instead of creating one",
+		},
+		{
+			name:    "track positional string",
+			dslCode: `track("Bass").setMute(mute=true)`,
+			wantMsg: `track reference must be a positive integer, got "Bass"`,
+		},
+		{
+			name:    "track mixed positional and keyword",
+			dslCode: `track(1, name="Bass")`,
+			wantMsg: "positional arguments are not supported, use name=value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewParser().ParseDSL(tt.dslCode)
+			if tt.wantMsg == "" {
+				if err != nil {
+					t.Errorf("ParseDSL() error = %v", err)
+				}
+				return
+			}
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("ParseDSL() error = %v, want *ParseError", err)
+			}
+			if perr.Msg != tt.wantMsg {
+				t.Errorf("ParseDSL() error = %q, want %q", perr.Msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestValidateArgs_position(t *testing.T) {
+	_, err := NewParser().ParseDSL(`track().setVolume(volume_db="loud")`)
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("ParseDSL() error = %v, want *ParseError", err)
+	}
+	if perr.Method != "setVolume" || perr.Pos.Column != 19 {
+		t.Errorf("ParseDSL() error at %s in %q, want column 19 in setVolume", perr.Pos, perr.Method)
+	}
+	want := "track().setVolume(volume_db=\"loud\")\n                  ^~~~~~~~~"
+	if perr.Snippet != want {
+		t.Errorf("ParseDSL() snippet =\n%s\nwant\n%s", perr.Snippet, want)
+	}
+}
@@ -0,0 +1,508 @@
+package dsl
+
+import "slices"
+
+// trackKeyword is the identifier that starts a track call
+const trackKeyword = "track"
+
+// tracksKeyword is the identifier that starts a tracks(...) selector
+const tracksKeyword = "tracks"
+
+// selectAllKeyword selects every track in tracks(all)
+const selectAllKeyword = "all"
+
+// asKeyword binds a name to a track: track(name="Bass") as bass
+const asKeyword = "as"
+
+// letKeyword starts a binding: let bars = 8
+const letKeyword = "let"
+
+// reservedNames cannot be bound with as or let
+var reservedNames = []string{trackKeyword, tracksKeyword, selectAllKeyword, asKeyword, letKeyword, "true", "false", "True", "False"}
+
+// Parse parses DSL source code into a Program AST
+// It stops at the first syntax error and returns it as a *ParseError.
+// Example: track(instrument="Serum").newClip(bar=1)
+// Returns: Program{Statements: [{Track: track(instrument="Serum"), Chain: [newClip(bar=1)]}]}
+func Parse(src string) (*Program, error) {
+	prog, errs := parseSource(src, false)
+	if len(errs) > 0 {
+		return nil, withSource(errs[0], src)
+	}
+	return prog, nil
+}
+
+// ParsePartial parses DSL source code, recovering from syntax errors
+// Parsing resumes at the next method or statement boundary after each error. The returned
+// Program holds every statement that parsed; if any errors occurred, they are returned as ParseErrors.
+// Method calls that fail to parse are dropped from their chain, and statements whose track
+// call fails to parse are dropped entirely.
+func ParsePartial(src string) (*Program, error) {
+	prog, errs := parseSource(src, true)
+	if len(errs) > 0 {
+		return prog, withSource(errs, src)
+	}
+	return prog, nil
+}
+
+// parseSource tokenizes and parses src, returning errors in source order
+// Without recovery, parsing stops at the first error.
+func parseSource(src string, recoverErrors bool) (*Program, ParseErrors) {
+	tokens, comments, errs := tokenize(src)
+	if len(errs) > 0 && !recoverErrors {
+		return nil, errs[:1]
+	}
+
+	ap := &astParser{tokens: tokens, recoverErrors: recoverErrors}
+	prog := ap.parseProgram()
+	prog.Comments = comments
+	errs = append(errs, ap.errs...)
+	if len(errs) > 0 && !recoverErrors {
+		return nil, errs[:1]
+	}
+
+	errs.sort()
+	return prog, errs
+}
+
+// astParser is a recursive-descent parser over a token stream
+type astParser struct {
+	tokens        []token
+	pos           int
+	recoverErrors bool        // Continue at the next method or statement boundary after an error
+	errs          ParseErrors // Errors seen so far; at most one unless recoverErrors is set
+}
+
+func (ap *astParser) peek() token {
+	return ap.tokens[ap.pos]
+}
+
+func (ap *astParser) next() token {
+	tok := ap.tokens[ap.pos]
+	if tok.kind != tokEOF {
+		ap.pos++
+	}
+	return tok
+}
+
+// prevEnd returns the end offset of the last consumed token
+func (ap *astParser) prevEnd() int {
+	if ap.pos == 0 {
+		return 0
+	}
+	return ap.tokens[ap.pos-1].end
+}
+
+// expect consumes a token of the given kind or returns an error
+// A mismatched token is not consumed, so recovery can resume from it.
+func (ap *astParser) expect(kind tokenKind) (token, error) {
+	tok := ap.peek()
+	if tok.kind != kind {
+		return tok, ap.errorf(tok, "expected %s, got %s", kind, tok)
+	}
+	return ap.next(), nil
+}
+
+// errorf creates a ParseError underlining tok
+func (ap *astParser) errorf(tok token, format string, args ...interface{}) error {
+	return newParseError(tok.pos, tok.end-tok.pos.Offset, "", format, args...)
+}
+
+// recordError stores err and, in recovery mode, skips to the next method or statement boundary
+// start is the token position where the failed construct began. Returns false if parsing must stop.
+func (ap *astParser) recordError(err error, start int) bool {
+	ap.errs = append(ap.errs, asParseError(err, ap.peek().pos))
+	if !ap.recoverErrors {
+		return false
+	}
+
+	// Always make progress, then skip to a '.' or a new track( call
+	if ap.pos == start {
+		ap.next()
+	}
+	for !ap.atBoundary() {
+		ap.next()
+	}
+	return true
+}
+
+// atBoundary reports whether the next token starts a method call or a statement
+func (ap *astParser) atBoundary() bool {
+	tok := ap.peek()
+	return tok.kind == tokEOF || tok.kind == tokDot || ap.atTrackCall() || ap.atBoundName() || (tok.kind == tokIdent && tok.text == letKeyword)
+}
+
+// atBoundName reports whether the next tokens are a name followed by "." as in bass.newClip
+func (ap *astParser) atBoundName() bool {
+	tok := ap.peek()
+	return tok.kind == tokIdent && !slices.Contains(reservedNames, tok.text) && ap.tokens[ap.pos+1].kind == tokDot
+}
+
+// atTrackCall reports whether the next tokens are "track" "(" or "tracks" "("
+func (ap *astParser) atTrackCall() bool {
+	tok := ap.peek()
+	return tok.kind == tokIdent && isTrackKeyword(tok.text) && ap.tokens[ap.pos+1].kind == tokLParen
+}
+
+func isTrackKeyword(name string) bool {
+	return name == trackKeyword || name == tracksKeyword
+}
+
+// program: statement*
+func (ap *astParser) parseProgram() *Program {
+	prog := &Program{}
+	for ap.peek().kind != tokEOF {
+		stmt, ok := ap.parseStatement()
+		if !ok {
+			return prog
+		}
+		if stmt != nil {
+			prog.Statements = append(prog.Statements, stmt)
+		}
+	}
+	return prog
+}
+
+// statement: (track_call | tracks_call) ("as" IDENT)? chain* | IDENT chain+ | let | chain+
+// Returns a nil statement if its track call failed to parse, and ok=false if parsing must stop.
+func (ap *astParser) parseStatement() (stmt *Statement, ok bool) {
+	start := ap.pos
+	tok := ap.peek()
+	stmt = &Statement{Pos: tok.pos}
+
+	switch {
+	case tok.kind == tokIdent && tok.text == letKeyword:
+		return ap.parseLet(stmt)
+	case tok.kind == tokIdent && isTrackKeyword(tok.text):
+		stmt = ap.parseTrackHead(stmt)
+		if stmt == nil && len(ap.errs) > 0 && !ap.recoverErrors {
+			return nil, false
+		}
+		if stmt != nil && ap.peek().kind == tokIdent && ap.peek().text == asKeyword {
+			bind, err := ap.parseBinding()
+			if err == nil && stmt.Track.Selector {
+				err = newParseError(bind.Pos, len(bind.Name), tracksKeyword, "a selector cannot be bound, bind a single track(...) instead")
+			}
+			if err != nil {
+				if !ap.recordError(err, ap.pos) {
+					return nil, false
+				}
+				stmt = nil
+			} else {
+				stmt.Bind = bind
+			}
+		}
+	case ap.atBoundName():
+		// Chain on a bound name - resolved against the binding
+		ap.next()
+		stmt.Target = &Ident{Pos: tok.pos, Name: tok.text}
+	case tok.kind == tokDot:
+		// Bare chain without a track call - resolved against the selected track
+	default:
+		if !ap.recordError(ap.errorf(tok, "expected track call, got %s", tok), start) {
+			return nil, false
+		}
+		stmt = nil
+	}
+
+	return ap.parseChain(stmt)
+}
+
+// parseTrackHead parses the track call that starts stmt
+// Returns nil if the call failed to parse; the error is recorded.
+func (ap *astParser) parseTrackHead(stmt *Statement) *Statement {
+	start := ap.pos
+	track, err := ap.parseTrackCall()
+	if err != nil {
+		ap.recordError(err, start)
+		// Keep checking the chain for errors, but drop the statement
+		return nil
+	}
+	stmt.Track = track
+	return stmt
+}
+
+// parseChain parses the method chain of stmt, which is nil if its start failed to parse
+func (ap *astParser) parseChain(stmt *Statement) (*Statement, bool) {
+	for ap.peek().kind == tokDot {
+		callStart := ap.pos
+		call, err := ap.parseMethodCall()
+		if err != nil {
+			if !ap.recordError(err, callStart) {
+				return nil, false
+			}
+			continue
+		}
+		if stmt != nil {
+			stmt.Chain = append(stmt.Chain, call)
+		}
+	}
+
+	if stmt != nil {
+		stmt.End = ap.prevEnd()
+	}
+	return stmt, true
+}
+
+// let: "let" IDENT "=" ((track_call | tracks_call | IDENT) chain* | value)
+// A value binding takes no chain, so a following .method() starts a new statement.
+func (ap *astParser) parseLet(stmt *Statement) (*Statement, bool) {
+	start := ap.pos
+	ap.next()
+	bind, err := ap.parseName()
+	if err == nil {
+		_, err = ap.expect(tokAssign)
+	}
+	if err != nil {
+		if !ap.recordError(err, start) {
+			return nil, false
+		}
+		return ap.parseChain(nil)
+	}
+	stmt.Bind, stmt.Let = bind, true
+
+	tok := ap.peek()
+	switch {
+	case tok.kind == tokIdent && isTrackKeyword(tok.text):
+		stmt = ap.parseTrackHead(stmt)
+		if stmt == nil && !ap.recoverErrors {
+			return nil, false
+		}
+		if stmt != nil && stmt.Track.Selector {
+			if !ap.recordError(newParseError(bind.Pos, len(bind.Name), tracksKeyword, "a selector cannot be bound, bind a single track(...) instead"), ap.pos) {
+				return nil, false
+			}
+			stmt = nil
+		}
+	case tok.kind == tokIdent && !isBoolKeyword(tok.text):
+		// let lead = bass - another name for a bound track or value
+		ap.next()
+		stmt.Target = &Ident{Pos: tok.pos, Name: tok.text}
+	default:
+		valueStart := ap.pos
+		value, err := ap.parseValue()
+		if err != nil {
+			if !ap.recordError(err, valueStart) {
+				return nil, false
+			}
+			return nil, true
+		}
+		stmt.Value, stmt.End = value, ap.prevEnd()
+		return stmt, true
+	}
+
+	return ap.parseChain(stmt)
+}
+
+// binding: "as" IDENT
+func (ap *astParser) parseBinding() (*Ident, error) {
+	ap.next()
+	return ap.parseName()
+}
+
+// parseName parses a name to bind, which must not be reserved
+func (ap *astParser) parseName() (*Ident, error) {
+	name, err := ap.expect(tokIdent)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(reservedNames, name.text) {
+		return nil, ap.errorf(name, "%q is reserved and cannot be bound", name.text)
+	}
+	return &Ident{Pos: name.pos, Name: name.text}, nil
+}
+
+func isBoolKeyword(name string) bool {
+	return name == "true" || name == "false" || name == "True" || name == "False"
+}
+
+// track_call: "track" "(" args? ")"
+// tracks_call: "tracks" "(" selector_args? ")"
+func (ap *astParser) parseTrackCall() (*TrackCall, error) {
+	tok := ap.next()
+	selector := tok.text == tracksKeyword
+	parseArg := ap.parseArg
+	if selector {
+		parseArg = ap.parseSelectorArg
+	}
+	args, err := ap.parseArgList(parseArg)
+	if err != nil {
+		return nil, err
+	}
+	return &TrackCall{Pos: tok.pos, End: ap.prevEnd(), Selector: selector, Args: args}, nil
+}
+
+// method_call: "." IDENT "(" args? ")"
+func (ap *astParser) parseMethodCall() (*MethodCall, error) {
+	dot := ap.next()
+	name, err := ap.expect(tokIdent)
+	if err != nil {
+		return nil, err
+	}
+	args, err := ap.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	return &MethodCall{Pos: dot.pos, End: ap.prevEnd(), Name: name.text, Args: args}, nil
+}
+
+// args: "(" (arg ("," arg)*)? ")"
+func (ap *astParser) parseArgs() ([]*Arg, error) {
+	return ap.parseArgList(ap.parseArg)
+}
+
+// parseArgList parses a parenthesized, comma-separated list of arguments using parseArg
+func (ap *astParser) parseArgList(parseArg func() (*Arg, error)) ([]*Arg, error) {
+	if _, err := ap.expect(tokLParen); err != nil {
+		return nil, err
+	}
+
+	var args []*Arg
+	for ap.peek().kind != tokRParen {
+		arg, err := parseArg()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if ap.peek().kind != tokComma {
+			break
+		}
+		ap.next()
+	}
+
+	if _, err := ap.expect(tokRParen); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// arg: IDENT "=" value | value
+func (ap *astParser) parseArg() (*Arg, error) {
+	tok := ap.peek()
+	if tok.kind == tokIdent && ap.tokens[ap.pos+1].kind == tokAssign {
+		ap.next()
+		ap.next()
+		value, err := ap.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return &Arg{Pos: tok.pos, Name: tok.text, Value: value}, nil
+	}
+
+	value, err := ap.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &Arg{Pos: tok.pos, Value: value}, nil
+}
+
+// selector_arg: IDENT ("=" | "~") value | NUMBER ".." NUMBER | NUMBER | "all"
+func (ap *astParser) parseSelectorArg() (*Arg, error) {
+	tok := ap.peek()
+	switch {
+	case tok.kind == tokIdent && ap.tokens[ap.pos+1].kind == tokTilde:
+		ap.next()
+		ap.next()
+		value, err := ap.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return &Arg{Pos: tok.pos, Name: tok.text, Match: true, Value: value}, nil
+	case tok.kind == tokIdent && tok.text == selectAllKeyword:
+		ap.next()
+		return &Arg{Pos: tok.pos, Value: &Literal{Pos: tok.pos, Kind: KeywordLiteral, Text: tok.text}}, nil
+	case tok.kind == tokNumber && ap.tokens[ap.pos+1].kind == tokRange:
+		ap.next()
+		ap.next()
+		to, err := ap.expect(tokNumber)
+		if err != nil {
+			return nil, err
+		}
+		from := &Literal{Pos: tok.pos, Kind: NumberLiteral, Text: tok.text}
+		bound := &Literal{Pos: to.pos, Kind: NumberLiteral, Text: to.text}
+		lit := &Literal{Pos: tok.pos, Kind: RangeLiteral, Text: from.Text + ".." + bound.Text, Elems: []*Literal{from, bound}}
+		return &Arg{Pos: tok.pos, Value: lit}, nil
+	}
+	return ap.parseArg()
+}
+
+// value: STRING | NUMBER | BOOLEAN | array | object
+func (ap *astParser) parseValue() (*Literal, error) {
+	tok := ap.peek()
+	switch tok.kind {
+	case tokString:
+		ap.next()
+		return &Literal{Pos: tok.pos, Kind: StringLiteral, Text: tok.text}, nil
+	case tokNumber:
+		ap.next()
+		return &Literal{Pos: tok.pos, Kind: NumberLiteral, Text: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true", "True":
+			ap.next()
+			return &Literal{Pos: tok.pos, Kind: BoolLiteral, Text: BooleanTrue}, nil
+		case "false", "False":
+			ap.next()
+			return &Literal{Pos: tok.pos, Kind: BoolLiteral, Text: "false"}, nil
+		}
+		if !slices.Contains(reservedNames, tok.text) {
+			// A value bound with let, substituted during translation
+			ap.next()
+			return &Literal{Pos: tok.pos, Kind: NameLiteral, Text: tok.text}, nil
+		}
+	case tokLBracket:
+		return ap.parseArray()
+	case tokLBrace:
+		return ap.parseObject()
+	}
+	return nil, ap.errorf(tok, "expected value, got %s", tok)
+}
+
+// array: "[" (value ("," value)*)? "]"
+func (ap *astParser) parseArray() (*Literal, error) {
+	open := ap.next()
+	lit := &Literal{Pos: open.pos, Kind: ArrayLiteral}
+	for ap.peek().kind != tokRBracket {
+		elem, err := ap.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		lit.Elems = append(lit.Elems, elem)
+		if ap.peek().kind != tokComma {
+			break
+		}
+		ap.next()
+	}
+	if _, err := ap.expect(tokRBracket); err != nil {
+		return nil, err
+	}
+	return lit, nil
+}
+
+// object: "{" (IDENT "=" value ("," IDENT "=" value)*)? "}"
+func (ap *astParser) parseObject() (*Literal, error) {
+	open := ap.next()
+	lit := &Literal{Pos: open.pos, Kind: ObjectLiteral}
+	for ap.peek().kind != tokRBrace {
+		key, err := ap.expect(tokIdent)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ap.expect(tokAssign); err != nil {
+			return nil, err
+		}
+		value, err := ap.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		lit.Fields = append(lit.Fields, &Arg{Pos: key.pos, Name: key.text, Value: value})
+		if ap.peek().kind != tokComma {
+			break
+		}
+		ap.next()
+	}
+	if _, err := ap.expect(tokRBrace); err != nil {
+		return nil, err
+	}
+	return lit, nil
+}
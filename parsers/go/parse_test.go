@@ -0,0 +1,404 @@
+package dsl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse_args(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		wantKeys []string
+	}{
+		{
+			name:     "single string param",
+			src:      `track(instrument="Serum")`,
+			wantKeys: []string{"instrument"},
+		},
+		{
+			name:     "multiple params",
+			src:      `track(instrument="Serum", name="Bass", index=2)`,
+			wantKeys: []string{"instrument", "name", "index"},
+		},
+		{
+			name:     "params with spaces",
+			src:      `track( instrument = "Serum" , name = "Bass" )`,
+			wantKeys: []string{"instrument", "name"},
+		},
+		{
+			name:     "empty params",
+			src:      `track()`,
+			wantKeys: []string{},
+		},
+		{
+			name:     "numeric params",
+			src:      `track().newClip(bar=3, length_bars=4)`,
+			wantKeys: []string{"bar", "length_bars"},
+		},
+		{
+			name:     "boolean params",
+			src:      `track().setMute(mute=true)`,
+			wantKeys: []string{"mute"},
+		},
+		{
+			name:     "float params",
+			src:      `track().setVolume(volume_db=-3.5)`,
+			wantKeys: []string{"volume_db"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			stmt := prog.Statements[0]
+			args := stmt.Track.Args
+			if len(stmt.Chain) > 0 {
+				args = stmt.Chain[0].Args
+			}
+
+			if len(args) != len(tt.wantKeys) {
+				t.Errorf("Parse() got %d args, want %d", len(args), len(tt.wantKeys))
+			}
+			for _, key := range tt.wantKeys {
+				if findArg(args, key) == nil {
+					t.Errorf("Parse() missing arg %s", key)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_literals(t *testing.T) {
+	prog, err := Parse(`track(instrument="Serum", name="Bass", index=2).setVolume(volume_db=-3.5).setMute(mute=true)`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	stmt := prog.Statements[0]
+
+	if got, _ := stmt.Track.Arg("instrument").Value.Str(); got != TestInstrumentName {
+		t.Errorf("instrument = %v, want %s", got, TestInstrumentName)
+	}
+	if got, _ := stmt.Track.Arg("name").Value.Str(); got != "Bass" {
+		t.Errorf("name = %v, want Bass", got)
+	}
+	if got, ok := stmt.Track.Arg("index").Value.Int(); !ok || got != 2 {
+		t.Errorf("index = %v, want 2", got)
+	}
+	if got, ok := stmt.Chain[0].Arg("volume_db").Value.Float(); !ok || got != -3.5 {
+		t.Errorf("volume_db = %v, want -3.5", got)
+	}
+	if got, ok := stmt.Chain[1].Arg("mute").Value.Bool(); !ok || !got {
+		t.Errorf("mute = %v, want true", got)
+	}
+}
+
+func TestParse_chains(t *testing.T) {
+	tests := []struct {
+		name           string
+		src            string
+		wantStatements int
+		wantChain      []string
+		wantFirstInstr string
+	}{
+		{
+			name:           "single method",
+			src:            `track(instrument="Serum")`,
+			wantStatements: 1,
+			wantFirstInstr: "Serum",
+		},
+		{
+			name:           "two methods",
+			src:            `track(instrument="Serum").newClip(bar=3)`,
+			wantStatements: 1,
+			wantChain:      []string{"newClip"},
+			wantFirstInstr: "Serum",
+		},
+		{
+			name:           "three methods",
+			src:            `track(instrument="Serum").newClip(bar=3).setVolume(volume_db=-3.0)`,
+			wantStatements: 1,
+			wantChain:      []string{"newClip", "setVolume"},
+			wantFirstInstr: "Serum",
+		},
+		{
+			name:           "nested strings",
+			src:            `track(instrument="VSTi: Serum (Xfer Records)").newClip(bar=3)`,
+			wantStatements: 1,
+			wantChain:      []string{"newClip"},
+			wantFirstInstr: "VSTi: Serum (Xfer Records)",
+		},
+		{
+			name:           "multi-line chain",
+			src:            "track(instrument=\"Serum\")\n  .newClip(bar=1)\n  .setPan(pan=-0.3)",
+			wantStatements: 1,
+			wantChain:      []string{"newClip", "setPan"},
+			wantFirstInstr: "Serum",
+		},
+		{
+			name:           "multiple statements",
+			src:            "track(instrument=\"Serum\").newClip(bar=1)\ntrack(instrument=\"Massive\").newClip(bar=5)",
+			wantStatements: 2,
+			wantChain:      []string{"newClip"},
+			wantFirstInstr: "Serum",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(prog.Statements) != tt.wantStatements {
+				t.Fatalf("Parse() statements = %d, want %d", len(prog.Statements), tt.wantStatements)
+			}
+
+			first := prog.Statements[0]
+			if got, _ := first.Track.Arg("instrument").Value.Str(); got != tt.wantFirstInstr {
+				t.Errorf("Parse() instrument = %q, want %q", got, tt.wantFirstInstr)
+			}
+			if len(first.Chain) != len(tt.wantChain) {
+				t.Fatalf("Parse() chain len = %d, want %d", len(first.Chain), len(tt.wantChain))
+			}
+			for i, name := range tt.wantChain {
+				if first.Chain[i].Name != name {
+					t.Errorf("Parse() chain[%d] = %s, want %s", i, first.Chain[i].Name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_nestedValues(t *testing.T) {
+	src := `track().addMidi(notes=[{pitch=60, velocity=100, start=0, duration=1}, {pitch=64, tags=["a", [1, 2]]}])`
+	prog, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	notes := prog.Statements[0].Chain[0].Arg("notes").Value
+	if notes.Kind != ArrayLiteral || len(notes.Elems) != 2 {
+		t.Fatalf("notes = %v, want array of 2", notes)
+	}
+	first := notes.Elems[0]
+	if first.Kind != ObjectLiteral || len(first.Fields) != 4 {
+		t.Fatalf("notes[0] = %v, want object with 4 fields", first)
+	}
+	if pitch, _ := first.Field("pitch").Value.Int(); pitch != 60 {
+		t.Errorf("notes[0].pitch = %d, want 60", pitch)
+	}
+	tags := notes.Elems[1].Field("tags").Value
+	if tags.Kind != ArrayLiteral || len(tags.Elems) != 2 || tags.Elems[1].Kind != ArrayLiteral {
+		t.Errorf("notes[1].tags = %v, want nested array", tags)
+	}
+}
+
+func TestParse_positions(t *testing.T) {
+	prog, err := Parse("track(id=1)\n  .setPan(pan=0.5)")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	call := prog.Statements[0].Chain[0]
+	want := Pos{Offset: 14, Line: 2, Column: 3}
+	if call.Pos != want {
+		t.Errorf("setPan pos = %+v, want %+v", call.Pos, want)
+	}
+}
+
+func TestParse_comments(t *testing.T) {
+	src := `# Bass line
+track(name="Bass") // create
+  /* quieter,
+     for the verse */ .setVolume(volume_db=-6)#end`
+	prog, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(prog.Statements) != 1 || len(prog.Statements[0].Chain) != 1 {
+		t.Fatalf("Parse() = %v, want one statement with one method", prog.Statements)
+	}
+
+	want := []Comment{
+		{Pos: Pos{Offset: 0, Line: 1, Column: 1}, Text: "# Bass line"},
+		{Pos: Pos{Offset: 31, Line: 2, Column: 20}, Text: "// create"},
+		{Pos: Pos{Offset: 43, Line: 3, Column: 3}, Text: "/* quieter,\n     for the verse */"},
+		{Pos: Pos{Offset: 101, Line: 4, Column: 47}, Text: "#end"},
+	}
+	if len(prog.Comments) != len(want) {
+		t.Fatalf("Parse() got %d comments, want %d: %v", len(prog.Comments), len(want), prog.Comments)
+	}
+	for i, c := range prog.Comments {
+		if *c != want[i] {
+			t.Errorf("comment %d = %+v, want %+v", i, *c, want[i])
+		}
+	}
+}
+
+func TestParse_selector(t *testing.T) {
+	prog, err := Parse(`tracks(1..4, 7, all, name~"Drum*", muted=true).set_mute(mute=false)`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	call := prog.Statements[0].Track
+	if !call.Selector || len(call.Args) != 5 {
+		t.Fatalf("Parse() track call = %+v, want a selector with 5 args", call)
+	}
+
+	want := []struct {
+		name  string
+		match bool
+		kind  LiteralKind
+		text  string
+	}{
+		{"", false, RangeLiteral, "1..4"},
+		{"", false, NumberLiteral, "7"},
+		{"", false, KeywordLiteral, "all"},
+		{"name", true, StringLiteral, "Drum*"},
+		{"muted", false, BoolLiteral, "true"},
+	}
+	for i, w := range want {
+		arg := call.Args[i]
+		if arg.Name != w.name || arg.Match != w.match || arg.Value.Kind != w.kind || arg.Value.Text != w.text {
+			t.Errorf("arg %d = %s (%s), want %s %s", i, arg, arg.Value.Kind, w.kind, w.text)
+		}
+	}
+	if from, to := call.Args[0].Value.Elems[0], call.Args[0].Value.Elems[1]; from.Text != "1" || to.Text != "4" || to.Pos.Column != 11 {
+		t.Errorf("range bounds = %s at %s, %s at %s", from, from.Pos, to, to.Pos)
+	}
+	if got := call.String(); got != `tracks(1..4, 7, all, name~"Drum*", muted=true)` {
+		t.Errorf("String() = %s", got)
+	}
+}
+
+func TestParse_bindings(t *testing.T) {
+	prog, err := Parse("track(name=\"Bass\") as bass\nbass.addFX(fxname=\"ReaEQ\")")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(prog.Statements) != 2 {
+		t.Fatalf("Parse() got %d statements, want 2", len(prog.Statements))
+	}
+	first, second := prog.Statements[0], prog.Statements[1]
+	if first.Bind == nil || first.Bind.Name != "bass" || first.Bind.Pos.Column != 23 {
+		t.Errorf("first statement binds %+v, want bass at column 23", first.Bind)
+	}
+	if second.Track != nil || second.Target == nil || second.Target.Name != "bass" || len(second.Chain) != 1 {
+		t.Errorf("second statement = %+v, want bass.addFX(...)", second)
+	}
+	if got := second.String(); got != `bass.addFX(fxname="ReaEQ")` {
+		t.Errorf("String() = %s", got)
+	}
+}
+
+func TestParse_let(t *testing.T) {
+	prog, err := Parse("let bars = 8\nlet lead = track(name=\"Lead\").newClip(bar=1, length_bars=bars)\nlet solo = lead")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(prog.Statements) != 3 {
+		t.Fatalf("Parse() got %d statements, want 3", len(prog.Statements))
+	}
+	bars, lead, solo := prog.Statements[0], prog.Statements[1], prog.Statements[2]
+	if !bars.Let || bars.Bind.Name != "bars" || bars.Value == nil || bars.Value.Text != "8" || len(bars.Chain) != 0 {
+		t.Errorf("first statement = %+v, want let bars = 8", bars)
+	}
+	if arg := lead.Chain[0].Arg("length_bars"); arg == nil || arg.Value.Kind != NameLiteral || arg.Value.Text != "bars" {
+		t.Errorf("length_bars = %v, want the name bars", arg)
+	}
+	if solo.Target == nil || solo.Target.Name != "lead" || solo.Track != nil {
+		t.Errorf("third statement = %+v, want let solo = lead", solo)
+	}
+	for i, want := range []string{`let bars = 8`, `let lead = track(name="Lead").newClip(bar=1, length_bars=bars)`, `let solo = lead`} {
+		if got := prog.Statements[i].String(); got != want {
+			t.Errorf("String() = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{name: "stray text", src: `track().newClip(bar=1) hello`},
+		{name: "missing close paren", src: `track(instrument="Serum"`},
+		{name: "unterminated string", src: `track(instrument="Serum)`},
+		{name: "unterminated array", src: `track().addMidi(notes=[{pitch=60}`},
+		{name: "object without key", src: `track().addMidi(notes=[{60}])`},
+		{name: "reserved word as value", src: `track().setVolume(volume_db=as)`},
+		{name: "unexpected character", src: `track(name="Bass") ; track()`},
+		{name: "missing method name", src: `track().(bar=1)`},
+		{name: "unterminated block comment", src: `track() /* no end`},
+		{name: "single slash", src: `track() / comment`},
+		{name: "range outside a selector", src: `track(1..4)`},
+		{name: "pattern outside a selector", src: `track(name~"Bass")`},
+		{name: "range without an end", src: `tracks(1..)`},
+		{name: "as without a name", src: `track() as`},
+		{name: "binding a reserved name", src: `track() as track`},
+		{name: "binding a selector", src: `tracks(1..4) as drums`},
+		{name: "bound name without a chain", src: `track() as bass
+bass`},
+		{name: "let without a value", src: `let bars =`},
+		{name: "let without a name", src: `let = 8`},
+		{name: "let of a reserved name", src: `let track = 8`},
+		{name: "let of a selector", src: `let drums = tracks(all)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.src); err == nil {
+				t.Errorf("Parse(%q) expected error", tt.src)
+			}
+		})
+	}
+}
+
+func TestParsePartial(t *testing.T) {
+	src := `track(name="Bass").newClip(bar=1 .setPan(pan=0.5)
+track(name=).setMute(mute=true)
+oops(solo=true).setSolo(solo=true)
+track(name="Lead") ; .setVolume(volume_db=-3)
+.(bar=1)`
+
+	prog, err := ParsePartial(src)
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ParsePartial() error = %v, want ParseErrors", err)
+	}
+	if len(errs) != 5 {
+		t.Fatalf("ParsePartial() got %d errors, want 5:\n%v", len(errs), errs)
+	}
+	for i := 1; i < len(errs); i++ {
+		if errs[i].Pos.Offset < errs[i-1].Pos.Offset {
+			t.Errorf("errors not in source order: %v before %v", errs[i-1], errs[i])
+		}
+	}
+
+	// Bass keeps setPan, the broken track(name=) and oops statements are dropped,
+	// and Lead keeps setVolume plus the chain after the stray ';'
+	if len(prog.Statements) != 2 {
+		t.Fatalf("ParsePartial() got %d statements, want 2", len(prog.Statements))
+	}
+	bass := prog.Statements[0]
+	if len(bass.Chain) != 1 || bass.Chain[0].Name != "setPan" {
+		t.Errorf("Bass chain = %v, want [setPan]", bass.Chain)
+	}
+	lead := prog.Statements[1]
+	if len(lead.Chain) != 1 || lead.Chain[0].Name != "setVolume" {
+		t.Errorf("Lead chain = %v, want [setVolume]", lead.Chain)
+	}
+}
+
+func TestParsePartial_noErrors(t *testing.T) {
+	prog, err := ParsePartial(`track().newClip(bar=1)`)
+	if err != nil {
+		t.Fatalf("ParsePartial() error = %v", err)
+	}
+	if len(prog.Statements) != 1 {
+		t.Errorf("ParsePartial() got %d statements, want 1", len(prog.Statements))
+	}
+}
@@ -3,7 +3,7 @@ package dsl
 import (
 	"fmt"
 	"log"
-	"strconv"
+	"slices"
 	"strings"
 )
 
@@ -18,8 +18,17 @@ const (
 
 // Parser parses MAGDA DSL code and translates it to DAW actions
 type Parser struct {
-	trackCounter int                    // Track index counter for implicit track references
-	state        map[string]interface{} // Current DAW state for track resolution
+	trackCounter  int           // Index the next track(...) without index= creates; see NextTrackIndex
+	state         *ProjectState // Current DAW state for track resolution
+	tracks        *ProjectState // Copy of state with the actions translated so far applied
+	recoverErrors bool          // Report every error in one pass instead of stopping at the first
+	canonicalOnly bool          // Reject method aliases such as newClip in favour of new_clip
+	allowUnknown  bool          // Skip unknown methods instead of reporting them as errors
+	resolveNames  bool          // Resolve track(name=...) to an existing track of that name
+	handles       bool          // Give created tracks handles that later actions reference; see SetTrackHandles
+	handleCounter int           // Number of handles generated so far
+
+	bindings map[string]*binding // Names bound with track(...) as name or let name = ...
 }
 
 // NewParser creates a new DSL parser
@@ -31,634 +40,808 @@ func NewParser() *Parser {
 }
 
 // SetState sets the current DAW state for track resolution
-func (p *Parser) SetState(state map[string]interface{}) {
+// Use DecodeState to build it from the JSON a DAW integration sends; nil clears it.
+// It also resets the track counter, so new tracks are appended after the state's last track,
+// and the bindings. References resolve against a copy of the state with the actions translated
+// since applied, so track(ref="Bass") after a .delete() still finds the track named Bass, and
+// a later ParseDSL call sees the tracks an earlier one created.
+func (p *Parser) SetState(state *ProjectState) {
 	p.state = state
+	p.ResetTrackCounter()
+	p.ResetBindings()
 }
 
-// ParseDSL parses DSL code and returns DAW actions
+// NextTrackIndex returns the 0-based index the next created track will get
+// Each track(...) call without index= creates its track at this index and advances it.
+// index=N at or past the counter moves it to N+1, and an insert before it or a .delete()
+// moves it by one, so new tracks keep going after the last track. The counter carries over
+// between ParseDSL calls, so a program can be parsed in pieces; call ResetTrackCounter or
+// SetState to start over.
+func (p *Parser) NextTrackIndex() int {
+	return p.trackCounter
+}
+
+// ResetTrackCounter moves the track counter back to just after the state's last track
+// Without a state, new tracks start at index 0. References forget the actions translated
+// since, and resolve against the state as it was set.
+func (p *Parser) ResetTrackCounter() {
+	p.trackCounter = 0
+	p.tracks = nil
+	if p.state != nil {
+		p.trackCounter = len(p.state.Tracks)
+		p.tracks = p.state.Copy()
+	}
+}
+
+// SetErrorRecovery enables or disables error recovery mode
+// With recovery enabled, ParseDSL resumes at the next method or statement boundary after an
+// error. It returns the actions that did translate together with a ParseErrors listing every error.
+func (p *Parser) SetErrorRecovery(enabled bool) {
+	p.recoverErrors = enabled
+}
+
+// SetCanonicalMethodsOnly restricts method names to the canonical spelling from spec/grammar.md
+// By default both spellings are accepted (e.g. .new_clip and .newClip). When enabled,
+// aliases such as .newClip are reported as errors naming the canonical spelling.
+func (p *Parser) SetCanonicalMethodsOnly(enabled bool) {
+	p.canonicalOnly = enabled
+}
+
+// SetAllowUnknownMethods controls how methods missing from the method table are handled
+// By default they are errors with a "did you mean" suggestion. When allowed, they are
+// skipped with a log message and produce no action.
+func (p *Parser) SetAllowUnknownMethods(allowed bool) {
+	p.allowUnknown = allowed
+}
+
+// SetResolveTrackNames controls whether track(name="Bass") can reference an existing track
+// By default it always creates a track. When enabled, a track call with only a name references
+// the track of that name in the state, ignoring case, and creates one only if there is none.
+// Unlike track(ref=...), near misses are not matched, so "Bass 2" still creates a new track.
+func (p *Parser) SetResolveTrackNames(enabled bool) {
+	p.resolveNames = enabled
+}
+
+// ParseDSL parses DSL code and returns DAW actions as maps
+// It is ParseProgram with each action converted by Action.Map; the maps encode to the same JSON.
+// Errors are *ParseError values carrying the source position; use errors.As to inspect them.
+// In error recovery mode, the error is a ParseErrors and the successfully translated actions are still returned.
 // Example: track(instrument="Serum").newClip(bar=3, length_bars=4)
 // Returns: [{"action": "create_track", "instrument": "Serum"}, {"action": "create_clip_at_bar", "track": 0, "bar": 3, "length_bars": 4}]
-//
-//nolint:gocyclo // Complex parsing logic is necessary for DSL translation
 func (p *Parser) ParseDSL(dslCode string) ([]map[string]interface{}, error) {
-	dslCode = strings.TrimSpace(dslCode)
-	if dslCode == "" {
-		return nil, fmt.Errorf("empty DSL code")
+	actions, err := p.ParseProgram(dslCode)
+	return ActionMaps(actions), err
+}
+
+// ParseProgram parses DSL code and returns typed DAW actions
+// Errors are reported the same way as ParseDSL. A program that fails, also in error recovery
+// mode, leaves the track counter, handles and bindings as they were, so it can be fixed and
+// parsed again.
+// Example: track(instrument="Serum").newClip(bar=3, length_bars=4)
+// Returns: [CreateTrack{Instrument: "Serum"}, CreateClipAtBar{Track: 0, Bar: 3, LengthBars: 4}]
+func (p *Parser) ParseProgram(dslCode string) ([]Action, error) {
+	saved := p.save()
+	actions, err := p.parseProgram(dslCode)
+	if err != nil {
+		p.restore(saved)
+	}
+	return actions, err
+}
+
+// parseProgram parses and translates a program, updating the parser as it goes
+func (p *Parser) parseProgram(dslCode string) ([]Action, error) {
+	if strings.TrimSpace(dslCode) == "" {
+		return nil, newParseError(Pos{Line: 1, Column: 1}, 0, "", "empty DSL code")
 	}
 
-	var actions []map[string]interface{}
-	currentTrackIndex := -1
+	// Parse the untrimmed source so error positions match the caller's input
+	prog, errs := parseSource(dslCode, p.recoverErrors)
+	if len(errs) > 0 && !p.recoverErrors {
+		return nil, withSource(errs[0], dslCode)
+	}
 
-	// Split by method chains (e.g., track().newClip().addMidi())
-	// Simple regex-based parser for now - can be enhanced with proper AST later
-	parts := p.splitMethodChains(dslCode)
+	actions, translateErrs := p.translateProgram(prog)
+	errs = append(errs, translateErrs...)
+	if len(errs) > 0 {
+		if !p.recoverErrors {
+			return nil, withSource(errs[0], dslCode)
+		}
+		errs.sort()
+		log.Printf("⚠️  DSL Parser: Translated %d actions with %d errors", len(actions), len(errs))
+		return actions, withSource(errs, dslCode)
+	}
 
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
+	if len(prog.Statements) == 0 {
+		// Only comments
+		return nil, withSource(newParseError(Pos{Line: 1, Column: 1}, 0, "", "no actions found in DSL code"), dslCode)
+	}
+	if len(actions) == 0 && !slices.ContainsFunc(prog.Statements, func(stmt *Statement) bool { return stmt.Bind != nil }) {
+		// A program of bindings only, such as let bars = 8, is fine on its own
+		return nil, withSource(newParseError(prog.Statements[0].Pos, 0, "", "no actions found in DSL code"), dslCode)
+	}
+
+	log.Printf("✅ DSL Parser: Translated %d actions from DSL", len(actions))
+	return actions, nil
+}
+
+// parserState is the part of a Parser that translating a program changes
+type parserState struct {
+	trackCounter  int
+	handleCounter int
+	tracks        *ProjectState // Working copy of the state; nil without a state
+	bindings      map[string]*binding
+}
+
+// save records the parser state, so a failed program can be undone with restore
+func (p *Parser) save() parserState {
+	saved := parserState{trackCounter: p.trackCounter, handleCounter: p.handleCounter}
+	if p.tracks != nil {
+		saved.tracks = p.tracks.Copy()
+	}
+	if p.bindings != nil {
+		// Deletes and inserts update bindings in place, so copy each one
+		saved.bindings = make(map[string]*binding, len(p.bindings))
+		for name, b := range p.bindings {
+			copied := *b
+			saved.bindings[name] = &copied
 		}
+	}
+	return saved
+}
 
-		// Parse track() call - could be creation or reference
-		if strings.HasPrefix(part, "track(") {
-			// Check if this is a track reference (track(id), track(1), or track(selected=true))
-			params := p.extractParams(part)
-			if idStr, hasID := params["id"]; hasID {
-				// track(id=1) - reference existing track
-				if trackNum, err := strconv.Atoi(idStr); err == nil {
-					currentTrackIndex = trackNum - 1 // Convert 1-based to 0-based
-					// No action needed - just set the track context for chaining
-					continue
-				}
-			} else if selectedStr, hasSelected := params["selected"]; hasSelected {
-				// track(selected=true) - reference currently selected track
-				// NOTE: Currently returns first selected track only (DAWs may support multiple selections)
-				if selectedStr == "true" || selectedStr == "True" {
-					selectedIndex := p.getSelectedTrackIndex()
-					if selectedIndex >= 0 {
-						currentTrackIndex = selectedIndex
-						// No action needed - just set the track context for chaining
-						continue
-					}
-					// If no selected track found, fall through to error or creation
-					return nil, fmt.Errorf("no selected track found in state")
-				}
-			} else if len(params) == 0 {
-				// Check if it's just track(1) - a bare number
-				// Extract content between parentheses
-				start := strings.Index(part, "(")
-				end := strings.LastIndex(part, ")")
-				if start >= 0 && end > start {
-					content := strings.TrimSpace(part[start+1 : end])
-					if trackNum, err := strconv.Atoi(content); err == nil {
-						// track(1) - reference existing track
-						currentTrackIndex = trackNum - 1 // Convert 1-based to 0-based
-						// No action needed - just set the track context for chaining
-						continue
-					}
-				}
-			}
+// restore puts back a parser state recorded by save
+func (p *Parser) restore(saved parserState) {
+	p.trackCounter, p.handleCounter = saved.trackCounter, saved.handleCounter
+	p.tracks, p.bindings = saved.tracks, saved.bindings
+}
 
-			// If we get here, it's a track creation call
-			trackAction, trackIndex, err := p.parseTrackCall(part)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse track call: %w", err)
-			}
-			actions = append(actions, trackAction)
-			currentTrackIndex = trackIndex
-		} else if strings.HasPrefix(part, ".newClip(") {
-			// Parse .newClip() call
-			// Use currentTrackIndex from track() or track(id) context, or fallback to selected track
-			trackIndex := currentTrackIndex
-			if trackIndex < 0 {
-				// No track context - use selected track from state as fallback
-				trackIndex = p.getSelectedTrackIndex()
-			}
-			clipAction, err := p.parseClipCall(part, trackIndex)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse clip call: %w", err)
-			}
-			actions = append(actions, clipAction)
-		} else if strings.HasPrefix(part, ".addMidi(") {
-			// Parse .addMidi() call
-			midiAction, err := p.parseMidiCall(part, currentTrackIndex)
+// translateProgram walks the AST and translates each statement to DAW actions
+// Without error recovery, translation stops at the first error.
+func (p *Parser) translateProgram(prog *Program) ([]Action, ParseErrors) {
+	var actions []Action
+	var errs ParseErrors
+	for _, stmt := range prog.Statements {
+		stmtActions, stmtErrs := p.translateStatement(stmt)
+		actions = append(actions, stmtActions...)
+		errs = append(errs, stmtErrs...)
+		if len(errs) > 0 && !p.recoverErrors {
+			return nil, errs
+		}
+	}
+	return actions, errs
+}
+
+// translateStatement resolves the statement's track context and translates its method chain
+// If the track call fails, the chain has no track to apply to; with error recovery it is still
+// checked, so its errors are reported too. A track call that references several tracks
+// translates the chain once per track.
+func (p *Parser) translateStatement(stmt *Statement) ([]Action, ParseErrors) {
+	var actions []Action
+	var errs ParseErrors
+	tracks := []int{-1} // Track context of the chain; -1 for none
+	ref := ""           // Handle of the track context, if it has one
+
+	if stmt.Value != nil {
+		// let bars = 8 - bind a value, with any names in it already substituted
+		value, err := p.substitute(stmt.Value)
+		if err != nil {
+			return nil, append(errs, asParseError(err, stmt.Pos))
+		}
+		p.bind(stmt.Bind.Name, &binding{value: value})
+		return nil, nil
+	}
+
+	if stmt.Target != nil {
+		if stmt.Let && len(stmt.Chain) == 0 {
+			// let lead = bass - another name for whatever bass is bound to
+			b, err := p.lookupBinding(stmt.Target)
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse midi call: %w", err)
+				return nil, append(errs, asParseError(err, stmt.Pos))
 			}
-			actions = append(actions, midiAction)
-		} else if strings.HasPrefix(part, ".addFX(") || strings.HasPrefix(part, ".addInstrument(") {
-			// Parse FX/instrument call
-			fxAction, err := p.parseFXCall(part, currentTrackIndex)
+			alias := *b
+			p.bind(stmt.Bind.Name, &alias)
+			return nil, nil
+		}
+
+		// bass.newClip(...) - the track bound to bass
+		b, err := p.lookupTrack(stmt.Target)
+		if err != nil {
+			return nil, p.trackContextErrors(stmt, err)
+		}
+		tracks, ref = []int{b.index}, b.ref
+	}
+
+	if stmt.Track != nil {
+		if err := p.substituteArgs(stmt.Track.Args); err != nil {
+			return nil, p.trackContextErrors(stmt, err)
+		}
+		if err := validateTrackArgs(stmt.Track); err != nil {
+			return nil, p.trackContextErrors(stmt, err)
+		}
+
+		// Check if this is a track reference (track(id), track(1), track(selected=true), track(ref=...) or tracks(...))
+		refTracks, isRef, err := p.resolveTrackReference(stmt.Track)
+		if err != nil {
+			return nil, p.trackContextErrors(stmt, err)
+		}
+		if isRef {
+			// No action needed - just set the track context for chaining
+			tracks = refTracks
+		} else {
+			trackAction, trackIndex, err := p.parseTrackCall(stmt.Track)
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse FX call: %w", err)
+				return nil, p.trackContextErrors(stmt, err)
 			}
-			actions = append(actions, fxAction)
-		} else if strings.HasPrefix(part, ".setVolume(") {
-			// Parse volume call
-			volumeAction, err := p.parseVolumeCall(part, currentTrackIndex)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse volume call: %w", err)
+			p.insertBoundTrack(trackIndex)
+			if p.handles {
+				trackAction.Ref = p.newHandle()
+				ref = trackAction.Ref
 			}
-			actions = append(actions, volumeAction)
-		} else if strings.HasPrefix(part, ".setPan(") {
-			// Parse pan call
-			panAction, err := p.parsePanCall(part, currentTrackIndex)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse pan call: %w", err)
-			}
-			actions = append(actions, panAction)
-		} else if strings.HasPrefix(part, ".setMute(") {
-			// Parse mute call
-			muteAction, err := p.parseMuteCall(part, currentTrackIndex)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse mute call: %w", err)
+			p.applyStateAction(trackAction, trackIndex)
+			actions = append(actions, trackAction)
+			tracks = []int{trackIndex}
+		}
+	}
+
+	if stmt.Bind != nil {
+		if err := p.bindTrack(stmt, tracks, ref); err != nil {
+			return nil, append(errs, asParseError(err, stmt.Pos))
+		}
+	}
+
+	if chainDeletesTrack(stmt.Chain) {
+		// Delete the last track first, so deleting one does not shift the indices of the others
+		slices.Reverse(tracks)
+	}
+	for i, trackIndex := range tracks {
+		chainActions, chainErrs := p.translateChain(stmt.Chain, trackIndex, ref)
+		actions = append(actions, chainActions...)
+		if i == 0 {
+			// The chain fails the same way on every track, so report its errors once
+			errs = append(errs, chainErrs...)
+		}
+		if len(errs) > 0 && !p.recoverErrors {
+			return nil, errs
+		}
+	}
+
+	return actions, errs
+}
+
+// translateChain translates a method chain applied to one track
+// If the track has a handle, each action references it with track_ref.
+func (p *Parser) translateChain(chain []*MethodCall, trackIndex int, ref string) ([]Action, ParseErrors) {
+	var actions []Action
+	var errs ParseErrors
+	trackDeleted := false
+	for _, call := range chain {
+		if trackDeleted {
+			errs = append(errs, afterDeleteError(call))
+			if !p.recoverErrors {
+				return nil, errs
 			}
-			actions = append(actions, muteAction)
-		} else if strings.HasPrefix(part, ".setSolo(") {
-			// Parse solo call
-			soloAction, err := p.parseSoloCall(part, currentTrackIndex)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse solo call: %w", err)
+			continue
+		}
+		action, err := p.translateMethodCall(call, trackIndex)
+		if err != nil {
+			errs = append(errs, asParseError(err, call.Pos))
+			if !p.recoverErrors {
+				return nil, errs
 			}
-			actions = append(actions, soloAction)
-		} else if strings.HasPrefix(part, ".setName(") {
-			// Parse name call
-			nameAction, err := p.parseNameCall(part, currentTrackIndex)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse name call: %w", err)
+			continue
+		}
+		if action == nil {
+			continue
+		}
+		if deleted, ok := action.(DeleteTrack); ok {
+			p.deleteBoundTrack(deleted.Track)
+			if deleted.Track < p.trackCounter {
+				p.trackCounter--
 			}
-			actions = append(actions, nameAction)
+			trackDeleted = true
+		}
+		if track, ok := action.(trackAction); ok && ref != "" {
+			action = track.withTrackRef(ref)
 		}
+		p.applyStateAction(action, actionTrackIndex(action))
+		actions = append(actions, action)
 	}
+	return actions, errs
+}
 
-	if len(actions) == 0 {
-		return nil, fmt.Errorf("no actions found in DSL code")
+// trackContextErrors reports err for a statement whose track context could not be resolved
+// With error recovery, the chain is also checked against a placeholder track and its actions are
+// discarded, so one pass reports the errors in the chain as well.
+func (p *Parser) trackContextErrors(stmt *Statement, err error) ParseErrors {
+	errs := ParseErrors{asParseError(err, stmt.Pos)}
+	if !p.recoverErrors {
+		return errs
+	}
+	trackDeleted := false
+	for _, call := range stmt.Chain {
+		if trackDeleted {
+			errs = append(errs, afterDeleteError(call))
+			continue
+		}
+		action, err := p.translateMethodCall(call, 0)
+		if err != nil {
+			errs = append(errs, asParseError(err, call.Pos))
+		}
+		if _, ok := action.(DeleteTrack); ok {
+			trackDeleted = true
+		}
 	}
+	return errs
+}
 
-	log.Printf("✅ DSL Parser: Translated %d actions from DSL", len(actions))
-	return actions, nil
+// afterDeleteError reports a method chained after .delete(), whose track no longer exists
+func afterDeleteError(call *MethodCall) *ParseError {
+	return callError(call, "cannot call %s after .delete(), the track no longer exists", call.Name)
 }
 
-// splitMethodChains splits DSL code into method calls
-// Example: "track(instrument=\"Serum\").newClip(bar=3)" -> ["track(instrument=\"Serum\")", ".newClip(bar=3)"]
-func (p *Parser) splitMethodChains(dslCode string) []string {
-	var parts []string
-	var current strings.Builder
-	depth := 0
-	inString := false
-	escape := false
+// chainDeletesTrack reports whether a method chain contains .delete()
+func chainDeletesTrack(chain []*MethodCall) bool {
+	for _, call := range chain {
+		if def, ok := lookupMethod(call.Name); ok && def.name == "delete" {
+			return true
+		}
+	}
+	return false
+}
 
-	for i, char := range dslCode {
-		if escape {
-			current.WriteRune(char)
-			escape = false
-			continue
+// translateMethodCall dispatches a chained method call to its translator via the method table
+// Unknown methods are errors with a "did you mean" suggestion, unless unknown methods are allowed,
+// in which case they are skipped and a nil action is returned.
+func (p *Parser) translateMethodCall(call *MethodCall, trackIndex int) (Action, error) {
+	def, ok := lookupMethod(call.Name)
+	if !ok {
+		if p.allowUnknown {
+			log.Printf("⚠️  DSL Parser: Skipping unknown method %q", call.Name)
+			return nil, nil
 		}
+		if suggestion := suggestMethod(call.Name, p.canonicalOnly); suggestion != "" {
+			return nil, callError(call, "unknown method %q, did you mean %q?", call.Name, suggestion)
+		}
+		return nil, callError(call, "unknown method %q", call.Name)
+	}
+	if p.canonicalOnly && call.Name != def.name {
+		return nil, callError(call, "non-canonical method spelling %q, use %q", call.Name, def.name)
+	}
+	if err := p.substituteArgs(call.Args); err != nil {
+		return nil, err
+	}
+	if err := validateArgs(call.Name, call.Pos, call.Args, def.params); err != nil {
+		return nil, err
+	}
+	return def.translate(p, call, trackIndex)
+}
 
-		switch char {
-		case '\\':
-			escape = true
-			current.WriteRune(char)
-		case '"':
-			inString = !inString
-			current.WriteRune(char)
-		case '(':
-			if !inString {
-				depth++
+// resolveTrackReference checks whether a track call references an existing track
+// Handles track(1), track(id=1), track(selected=true) and track(ref="Bass"), and track(name="Bass")
+// when track names are resolved. track(selected=true) and tracks(...) selectors return every track
+// they match, in state order; the other forms return one track. Returns isRef=false for track creation calls.
+func (p *Parser) resolveTrackReference(call *TrackCall) (tracks []int, isRef bool, err error) {
+	if call.Selector {
+		// tracks(...) - reference every track the selector matches
+		tracks, err := p.resolveSelector(call)
+		return tracks, err == nil, err
+	}
+
+	trackIndex, isRef, err := p.resolveSingleTrack(call)
+	if !isRef || err != nil {
+		return nil, false, err
+	}
+	if trackIndex >= 0 {
+		return []int{trackIndex}, true, nil
+	}
+
+	// track(selected=true) - reference every selected track
+	selected := p.tracks.SelectedTracks()
+	if len(selected) == 0 {
+		return nil, false, trackError(call, "no selected track found in state")
+	}
+	return selected, true, nil
+}
+
+// resolveSingleTrack resolves a track reference other than track(selected=true)
+// For track(selected=true) it returns trackIndex -1 with isRef=true, leaving the selection to the caller.
+func (p *Parser) resolveSingleTrack(call *TrackCall) (trackIndex int, isRef bool, err error) {
+	if arg := call.Arg("ref"); arg != nil {
+		// track(ref="Bass") - reference existing track by name
+		trackIndex, err := p.resolveTrackName(arg, p.tracks.MatchTracks(arg.Value.Text))
+		return trackIndex, err == nil, err
+	}
+
+	if arg := call.Arg("name"); arg != nil && p.resolveNames && len(call.Args) == 1 && p.state != nil {
+		// track(name="Bass") - reference the track named Bass if there is one
+		var matches []int
+		for i, track := range p.tracks.Tracks {
+			if strings.EqualFold(strings.TrimSpace(track.Name), strings.TrimSpace(arg.Value.Text)) {
+				matches = append(matches, i)
 			}
-			current.WriteRune(char)
-		case ')':
-			if !inString {
-				depth--
-				if depth == 0 {
-					current.WriteRune(char)
-					parts = append(parts, current.String())
-					current.Reset()
-					// Skip whitespace and dots after closing paren
-					for i+1 < len(dslCode) && (dslCode[i+1] == '.' || dslCode[i+1] == ' ' || dslCode[i+1] == '\n') {
-						i++
-					}
-					continue
-				}
+		}
+		if len(matches) > 0 {
+			trackIndex, err := p.resolveTrackName(arg, matches)
+			return trackIndex, err == nil, err
+		}
+		return -1, false, nil
+	}
+
+	if arg := call.Arg("id"); arg != nil {
+		// track(id=1) - reference existing track
+		trackNum, ok := arg.Value.Int()
+		if !ok {
+			return -1, false, argError(trackKeyword, arg, "track id must be an integer, got %s", arg.Value.Kind)
+		}
+		return trackNum - 1, true, nil // Convert 1-based to 0-based
+	}
+
+	if arg := call.Arg("selected"); arg != nil {
+		if text, ok := arg.Value.Str(); ok && text == selectedFirst {
+			// track(selected="first") - reference the first selected track only
+			selectedIndex := p.getSelectedTrackIndex()
+			if selectedIndex < 0 {
+				return -1, false, trackError(call, "no selected track found in state")
 			}
-			current.WriteRune(char)
-		default:
-			current.WriteRune(char)
+			return selectedIndex, true, nil
+		}
+		if selected, ok := arg.Value.Bool(); ok && selected {
+			// track(selected=true) - reference every selected track
+			return -1, true, nil
 		}
+		return -1, false, nil
 	}
 
-	if current.Len() > 0 {
-		parts = append(parts, current.String())
+	if len(call.Args) == 1 && call.Args[0].Name == "" {
+		// track(1) - reference existing track by bare number
+		trackNum, ok := call.Args[0].Value.Int()
+		if !ok {
+			return -1, false, argError(trackKeyword, call.Args[0], "track reference must be an integer, got %s", call.Args[0].Value.Kind)
+		}
+		return trackNum - 1, true, nil // Convert 1-based to 0-based
 	}
 
-	return parts
+	return -1, false, nil
 }
 
-// parseTrackCall parses track(instrument="Serum", name="Bass")
-func (p *Parser) parseTrackCall(call string) (map[string]interface{}, int, error) {
-	action := map[string]interface{}{
-		"action": "create_track",
+// resolveTrackName returns the single track a name argument matched
+// No match and several matches are errors; the latter list the candidates.
+func (p *Parser) resolveTrackName(arg *Arg, matches []int) (int, error) {
+	name := arg.Value.Text
+	switch {
+	case p.state == nil:
+		return -1, argError(trackKeyword, arg, "cannot resolve track %q: no project state set", name)
+	case len(matches) == 0:
+		return -1, argError(trackKeyword, arg, "no track named %q in project state", name)
+	case len(matches) > 1:
+		candidates := make([]string, len(matches))
+		for i, index := range matches {
+			candidates[i] = fmt.Sprintf("%d (%q)", index+1, p.tracks.Tracks[index].Name)
+		}
+		return -1, argError(trackKeyword, arg, "track name %q is ambiguous, it matches tracks %s", name, strings.Join(candidates, ", "))
 	}
+	return matches[0], nil
+}
 
-	// Extract parameters from track(...)
-	params := p.extractParams(call)
-	if instrument, ok := params["instrument"]; ok {
-		action["instrument"] = instrument
+// parseTrackCall parses track(instrument="Serum", name="Bass")
+func (p *Parser) parseTrackCall(call *TrackCall) (CreateTrack, int, error) {
+	var action CreateTrack
+
+	if instrument, ok := stringArg(call.Args, "instrument"); ok {
+		action.Instrument = instrument
 	}
-	if name, ok := params["name"]; ok {
-		action["name"] = name
+	if name, ok := stringArg(call.Args, "name"); ok {
+		action.Name = name
 	}
-	if indexStr, ok := params["index"]; ok {
-		if index, err := strconv.Atoi(indexStr); err == nil {
-			action["index"] = index
+	if index, ok := intArg(call.Args, "index"); ok {
+		action.Index = index
+		if index >= p.trackCounter {
 			p.trackCounter = index + 1
+		} else {
+			// Inserting before the end shifts the later tracks, so the end moves by one
+			p.trackCounter++
 		}
 	} else {
-		action["index"] = p.trackCounter
+		action.Index = p.trackCounter
 		p.trackCounter++
 	}
 
-	return action, action["index"].(int), nil
+	return action, action.Index, nil
 }
 
-// parseClipCall parses .newClip(bar=3, length_bars=4) or .newClip(start=1.5, length=2.0)
+// parseClipCall parses .new_clip(bar=3, length_bars=4) or .new_clip(start=1.5, length=2.0)
 // trackIndex should already be resolved (0-based) before calling this
-func (p *Parser) parseClipCall(call string, trackIndex int) (map[string]interface{}, error) {
+func (p *Parser) parseClipCall(call *MethodCall, trackIndex int) (Action, error) {
 	if trackIndex < 0 {
 		// Try fallback to selected track one more time
 		trackIndex = p.getSelectedTrackIndex()
 		if trackIndex < 0 {
-			return nil, fmt.Errorf("no track context for clip call and no selected track found")
+			return nil, callError(call, "no track context for clip call and no selected track found")
 		}
 	}
 
-	params := p.extractParams(call)
-	action := map[string]interface{}{
-		"action": "create_clip",
-		"track":  trackIndex,
+	// A clip is placed either by bar (bar, length_bars) or by time (start or position, length)
+	var barKeys, timeKeys []string
+	for _, key := range []string{"bar", "length_bars"} {
+		if call.Arg(key) != nil {
+			barKeys = append(barKeys, key)
+		}
+	}
+	for _, key := range []string{"start", "position", "length"} {
+		if call.Arg(key) != nil {
+			timeKeys = append(timeKeys, key)
+		}
+	}
+	if len(barKeys) > 0 && len(timeKeys) > 0 {
+		return nil, callError(call, "clip call cannot mix %s with %s, use bar and length_bars or start and length", strings.Join(barKeys, ", "), strings.Join(timeKeys, ", "))
+	}
+	if call.Arg("start") != nil && call.Arg("position") != nil {
+		return nil, callError(call, "clip call must specify only one of start or position")
 	}
 
-	if bar, ok := params["bar"]; ok {
+	if call.Arg("bar") != nil {
 		// Use create_clip_at_bar
-		action["action"] = "create_clip_at_bar"
-		if barInt, err := strconv.Atoi(bar); err == nil {
-			action["bar"] = barInt
+		action := CreateClipAtBar{Track: trackIndex, LengthBars: 4} // Default length
+		action.Bar, _ = intArg(call.Args, "bar")
+		if lengthBars, ok := intArg(call.Args, "length_bars"); ok {
+			action.LengthBars = lengthBars
 		}
-		if lengthBars, ok := params["length_bars"]; ok {
-			if lengthInt, err := strconv.Atoi(lengthBars); err == nil {
-				action["length_bars"] = lengthInt
-			}
-		} else {
-			action["length_bars"] = 4 // Default
-		}
-	} else if start, ok := params["start"]; ok {
-		// Use create_clip with time-based positioning
-		if startFloat, err := strconv.ParseFloat(start, 64); err == nil {
-			action["position"] = startFloat
-		}
-		if length, ok := params["length"]; ok {
-			if lengthFloat, err := strconv.ParseFloat(length, 64); err == nil {
-				action["length"] = lengthFloat
-			}
+		return action, nil
+	}
+
+	if call.Arg("start") != nil || call.Arg("position") != nil {
+		// Use create_clip with time-based positioning ("position" is an alias for "start")
+		action := CreateClip{Track: trackIndex, Length: 4.0} // Default length
+		if start, ok := floatArg(call.Args, "start"); ok {
+			action.Position = start
 		} else {
-			action["length"] = 4.0 // Default
+			action.Position, _ = floatArg(call.Args, "position")
 		}
-	} else if position, ok := params["position"]; ok {
-		// Alias for start
-		if posFloat, err := strconv.ParseFloat(position, 64); err == nil {
-			action["position"] = posFloat
+		if length, ok := floatArg(call.Args, "length"); ok {
+			action.Length = length
 		}
-		if length, ok := params["length"]; ok {
-			if lengthFloat, err := strconv.ParseFloat(length, 64); err == nil {
-				action["length"] = lengthFloat
-			}
-		} else {
-			action["length"] = 4.0 // Default
-		}
-	} else {
-		return nil, fmt.Errorf("clip call must specify bar or start/position")
+		return action, nil
 	}
 
-	return action, nil
+	return nil, callError(call, "clip call must specify bar or start/position")
 }
 
-// parseMidiCall parses .addMidi(notes=[...])
-func (p *Parser) parseMidiCall(_ string, trackIndex int) (map[string]interface{}, error) {
+// parseMidiCall parses .addMidi(notes=[{pitch=60, velocity=100, start=0, duration=1}, ...])
+func (p *Parser) parseMidiCall(call *MethodCall, trackIndex int) (Action, error) {
 	if trackIndex < 0 {
-		return nil, fmt.Errorf("no track context for midi call")
+		return nil, callError(call, "no track context for midi call")
 	}
 
-	// For now, return a placeholder - MIDI parsing is complex
-	// The extension will need to handle MIDI data
-	action := map[string]interface{}{
-		"action": "add_midi",
-		"track":  trackIndex,
-		"notes":  []interface{}{}, // Placeholder - will be populated from DSL
+	arg := call.Arg("notes")
+	if arg == nil {
+		return nil, callError(call, "midi call must specify notes")
+	}
+	notes, err := parseNotes(call, arg)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Printf("⚠️  MIDI parsing not yet implemented - returning placeholder")
-	return action, nil
+	return AddMidi{Track: trackIndex, Notes: notes}, nil
 }
 
 // parseFXCall parses .addFX(fxname="ReaEQ") or .addInstrument(instrument="Serum")
-func (p *Parser) parseFXCall(call string, trackIndex int) (map[string]interface{}, error) {
+func (p *Parser) parseFXCall(call *MethodCall, trackIndex int) (Action, error) {
 	if trackIndex < 0 {
-		return nil, fmt.Errorf("no track context for FX call")
+		return nil, callError(call, "no track context for FX call")
 	}
 
-	params := p.extractParams(call)
-	action := map[string]interface{}{
-		"action": "add_track_fx",
-		"track":  trackIndex,
+	if call.Arg("fxname") != nil && call.Arg("instrument") != nil {
+		return nil, callError(call, "FX call must specify only one of fxname or instrument")
 	}
-
-	if fxname, ok := params["fxname"]; ok {
-		action["fxname"] = fxname
-	} else if instrument, ok := params["instrument"]; ok {
-		action["action"] = "add_instrument"
-		action["fxname"] = instrument
-	} else {
-		return nil, fmt.Errorf("FX call must specify fxname or instrument")
+	if fxname, ok := stringArg(call.Args, "fxname"); ok {
+		return AddTrackFX{Track: trackIndex, FXName: fxname}, nil
 	}
-
-	return action, nil
+	if instrument, ok := stringArg(call.Args, "instrument"); ok {
+		return AddInstrument{Track: trackIndex, FXName: instrument}, nil
+	}
+	return nil, callError(call, "FX call must specify fxname or instrument")
 }
 
 // parseVolumeCall parses .setVolume(volume_db=-3.0)
-func (p *Parser) parseVolumeCall(call string, trackIndex int) (map[string]interface{}, error) {
+func (p *Parser) parseVolumeCall(call *MethodCall, trackIndex int) (Action, error) {
 	if trackIndex < 0 {
-		return nil, fmt.Errorf("no track context for volume call")
+		return nil, callError(call, "no track context for volume call")
 	}
 
-	params := p.extractParams(call)
-	action := map[string]interface{}{
-		"action": "set_track_volume",
-		"track":  trackIndex,
-	}
-
-	if volume, ok := params["volume_db"]; ok {
-		if volFloat, err := strconv.ParseFloat(volume, 64); err == nil {
-			action["volume_db"] = volFloat
-		}
-	} else {
-		return nil, fmt.Errorf("volume call must specify volume_db")
+	volume, ok := floatArg(call.Args, "volume_db")
+	if !ok {
+		return nil, callError(call, "volume call must specify volume_db")
 	}
 
-	return action, nil
+	return SetTrackVolume{Track: trackIndex, VolumeDB: volume}, nil
 }
 
 // parsePanCall parses .setPan(pan=0.5)
-func (p *Parser) parsePanCall(call string, trackIndex int) (map[string]interface{}, error) {
+func (p *Parser) parsePanCall(call *MethodCall, trackIndex int) (Action, error) {
 	if trackIndex < 0 {
-		return nil, fmt.Errorf("no track context for pan call")
-	}
-
-	params := p.extractParams(call)
-	action := map[string]interface{}{
-		"action": "set_track_pan",
-		"track":  trackIndex,
+		return nil, callError(call, "no track context for pan call")
 	}
 
-	if pan, ok := params["pan"]; ok {
-		if panFloat, err := strconv.ParseFloat(pan, 64); err == nil {
-			action["pan"] = panFloat
-		}
-	} else {
-		return nil, fmt.Errorf("pan call must specify pan")
+	pan, ok := floatArg(call.Args, "pan")
+	if !ok {
+		return nil, callError(call, "pan call must specify pan")
 	}
 
-	return action, nil
+	return SetTrackPan{Track: trackIndex, Pan: pan}, nil
 }
 
 // parseMuteCall parses .setMute(mute=true)
-func (p *Parser) parseMuteCall(call string, trackIndex int) (map[string]interface{}, error) {
+func (p *Parser) parseMuteCall(call *MethodCall, trackIndex int) (Action, error) {
 	if trackIndex < 0 {
-		return nil, fmt.Errorf("no track context for mute call")
-	}
-
-	params := p.extractParams(call)
-	action := map[string]interface{}{
-		"action": "set_track_mute",
-		"track":  trackIndex,
+		return nil, callError(call, "no track context for mute call")
 	}
 
-	if mute, ok := params["mute"]; ok {
-		action["mute"] = mute == BooleanTrue
-	} else {
-		return nil, fmt.Errorf("mute call must specify mute")
+	mute, ok := boolArg(call.Args, "mute")
+	if !ok {
+		return nil, callError(call, "mute call must specify mute")
 	}
 
-	return action, nil
+	return SetTrackMute{Track: trackIndex, Mute: mute}, nil
 }
 
 // parseSoloCall parses .setSolo(solo=true)
-func (p *Parser) parseSoloCall(call string, trackIndex int) (map[string]interface{}, error) {
+func (p *Parser) parseSoloCall(call *MethodCall, trackIndex int) (Action, error) {
 	if trackIndex < 0 {
-		return nil, fmt.Errorf("no track context for solo call")
-	}
-
-	params := p.extractParams(call)
-	action := map[string]interface{}{
-		"action": "set_track_solo",
-		"track":  trackIndex,
+		return nil, callError(call, "no track context for solo call")
 	}
 
-	if solo, ok := params["solo"]; ok {
-		action["solo"] = solo == BooleanTrue
-	} else {
-		return nil, fmt.Errorf("solo call must specify solo")
+	solo, ok := boolArg(call.Args, "solo")
+	if !ok {
+		return nil, callError(call, "solo call must specify solo")
 	}
 
-	return action, nil
+	return SetTrackSolo{Track: trackIndex, Solo: solo}, nil
 }
 
 // parseNameCall parses .setName(name="Bass")
-func (p *Parser) parseNameCall(call string, trackIndex int) (map[string]interface{}, error) {
+func (p *Parser) parseNameCall(call *MethodCall, trackIndex int) (Action, error) {
 	if trackIndex < 0 {
-		return nil, fmt.Errorf("no track context for name call")
+		return nil, callError(call, "no track context for name call")
 	}
 
-	params := p.extractParams(call)
-	action := map[string]interface{}{
-		"action": "set_track_name",
-		"track":  trackIndex,
-	}
-
-	if name, ok := params["name"]; ok {
-		action["name"] = name
-	} else {
-		return nil, fmt.Errorf("name call must specify name")
+	name, ok := stringArg(call.Args, "name")
+	if !ok {
+		return nil, callError(call, "name call must specify name")
 	}
 
-	return action, nil
+	return SetTrackName{Track: trackIndex, Name: name}, nil
 }
 
-// extractParams extracts key=value parameters from a function call
-// Example: track(instrument="Serum", name="Bass") -> {"instrument": "Serum", "name": "Bass"}
-//
-//nolint:gocyclo // Complex parsing logic is necessary for parameter extraction
-func (p *Parser) extractParams(call string) map[string]string {
-	params := make(map[string]string)
-
-	// Find the content between parentheses
-	start := strings.Index(call, "(")
-	end := strings.LastIndex(call, ")")
-	if start < 0 || end < 0 || end <= start {
-		return params
-	}
-
-	content := call[start+1 : end]
-	content = strings.TrimSpace(content)
-	if content == "" {
-		return params
+// parseDeleteCall parses .delete()
+func (p *Parser) parseDeleteCall(call *MethodCall, trackIndex int) (Action, error) {
+	if trackIndex < 0 {
+		// Fall back to selected track, same as clip calls
+		trackIndex = p.getSelectedTrackIndex()
+		if trackIndex < 0 {
+			return nil, callError(call, "no track context for delete call and no selected track found")
+		}
 	}
 
-	// Simple parameter parsing - split by comma, respecting strings
-	var currentKey strings.Builder
-	var currentValue strings.Builder
-	inString := false
-	escape := false
-	expectingValue := false
-	currentParamKey := ""
-
-	for _, char := range content {
-		if escape {
-			if inString {
-				currentValue.WriteRune(char)
-			}
-			escape = false
-			continue
-		}
+	return DeleteTrack{Track: trackIndex}, nil
+}
 
-		switch char {
-		case '\\':
-			escape = true
-			if inString {
-				currentValue.WriteRune(char)
-			}
-		case '"':
-			inString = !inString
-			if !inString {
-				// Ending string value
-				if currentParamKey != "" {
-					params[currentParamKey] = currentValue.String()
-					currentParamKey = ""
-					currentValue.Reset()
-					expectingValue = false
-				}
-			}
-		case '=':
-			if !inString {
-				currentParamKey = strings.TrimSpace(currentKey.String())
-				currentKey.Reset()
-				expectingValue = true
-			} else {
-				currentValue.WriteRune(char)
-			}
-		case ',':
-			if !inString {
-				if currentParamKey != "" && currentValue.Len() > 0 {
-					// Non-string value
-					valueStr := strings.TrimSpace(currentValue.String())
-					params[currentParamKey] = valueStr
-					currentParamKey = ""
-					currentValue.Reset()
-					currentKey.Reset()
-					expectingValue = false
-				}
-			} else {
-				currentValue.WriteRune(char)
-			}
-		default:
-			if expectingValue {
-				currentValue.WriteRune(char)
-			} else {
-				currentKey.WriteRune(char)
-			}
+// parseDeleteClipCall parses .delete_clip(clip=0), .delete_clip(bar=2) or .delete_clip(position=4.0)
+func (p *Parser) parseDeleteClipCall(call *MethodCall, trackIndex int) (Action, error) {
+	if trackIndex < 0 {
+		// Fall back to selected track, same as clip calls
+		trackIndex = p.getSelectedTrackIndex()
+		if trackIndex < 0 {
+			return nil, callError(call, "no track context for delete clip call and no selected track found")
 		}
 	}
 
-	// Handle last parameter
-	if currentParamKey != "" {
-		valueStr := strings.TrimSpace(currentValue.String())
-		if valueStr != "" {
-			params[currentParamKey] = valueStr
+	// Exactly one of clip, bar or position identifies the clip
+	var found []string
+	for _, key := range []string{"clip", "bar", "position"} {
+		if call.Arg(key) != nil {
+			found = append(found, key)
 		}
 	}
-
-	return params
-}
-
-// getSelectedTrackIndex returns the index of the currently selected track from state
-// Returns -1 if no selected track is found
-// NOTE: DAWs may support multiple selected tracks, but we currently only return the first one.
-// TODO: Handle multiple selected tracks in the future (e.g., return array or apply to all)
-func (p *Parser) getSelectedTrackIndex() int {
-	if p.state == nil {
-		return -1
+	if len(found) == 0 {
+		return nil, callError(call, "delete clip call must specify clip, bar or position")
 	}
-
-	// Navigate to tracks array - state is wrapped as {"state": {...}}
-	stateMap, ok := p.state["state"].(map[string]interface{})
-	if !ok {
-		return -1
-	}
-
-	tracks, ok := stateMap["tracks"].([]interface{})
-	if !ok {
-		return -1
+	if len(found) > 1 {
+		return nil, callError(call, "delete clip call must specify only one of clip, bar or position, got %s", strings.Join(found, ", "))
 	}
 
-	// Find first selected track
-	for i, track := range tracks {
-		trackMap, ok := track.(map[string]interface{})
-		if !ok {
-			continue
+	action := DeleteClip{Track: trackIndex}
+	switch found[0] {
+	case "clip":
+		if clip, ok := intArg(call.Args, "clip"); ok {
+			action.Clip = &clip
 		}
-		if selected, ok := trackMap["selected"].(bool); ok && selected {
-			return i
+	case "bar":
+		if bar, ok := intArg(call.Args, "bar"); ok {
+			action.Bar = &bar
+		}
+	case "position":
+		if position, ok := floatArg(call.Args, "position"); ok {
+			action.Position = &position
 		}
 	}
 
-	return -1
+	return action, nil
 }
 
-// resolveTrackIndex resolves a track reference (1-based number or name) to 0-based index
-// Returns -1 if not found
-func (p *Parser) resolveTrackIndex(trackRef string) int {
-	if p.state == nil {
-		return -1
-	}
-
-	// Try parsing as 1-based number first
-	if trackNum, err := strconv.Atoi(trackRef); err == nil {
-		// Convert 1-based to 0-based
-		trackIndex := trackNum - 1
-		// Verify track exists
-		if p.trackExists(trackIndex) {
-			return trackIndex
+// parseSelectedCall parses .set_selected(selected=true)
+func (p *Parser) parseSelectedCall(call *MethodCall, trackIndex int) (Action, error) {
+	if trackIndex < 0 {
+		// Fall back to selected track, same as clip calls
+		trackIndex = p.getSelectedTrackIndex()
+		if trackIndex < 0 {
+			return nil, callError(call, "no track context for selected call and no selected track found")
 		}
 	}
 
-	// Try matching by name
-	stateMap, ok := p.state["state"].(map[string]interface{})
+	selected, ok := boolArg(call.Args, "selected")
 	if !ok {
-		stateMap = p.state
+		return nil, callError(call, "selected call must specify selected")
 	}
 
-	tracks, ok := stateMap["tracks"].([]interface{})
-	if !ok {
-		return -1
-	}
+	return SetTrackSelected{Track: trackIndex, Selected: selected}, nil
+}
 
-	for i, track := range tracks {
-		trackMap, ok := track.(map[string]interface{})
-		if !ok {
-			continue
-		}
-		if name, ok := trackMap["name"].(string); ok && name == trackRef {
-			return i
-		}
+// stringArg returns the value of a string keyword argument
+func stringArg(args []*Arg, name string) (string, bool) {
+	arg := findArg(args, name)
+	if arg == nil {
+		return "", false
 	}
+	return arg.Value.Str()
+}
 
-	return -1
+// intArg returns the value of an integer keyword argument
+func intArg(args []*Arg, name string) (int, bool) {
+	arg := findArg(args, name)
+	if arg == nil {
+		return 0, false
+	}
+	return arg.Value.Int()
 }
 
-// trackExists checks if a track exists at the given index
-func (p *Parser) trackExists(index int) bool {
-	if p.state == nil || index < 0 {
-		return false
+// floatArg returns the value of a numeric keyword argument
+func floatArg(args []*Arg, name string) (float64, bool) {
+	arg := findArg(args, name)
+	if arg == nil {
+		return 0, false
 	}
+	return arg.Value.Float()
+}
 
-	stateMap, ok := p.state["state"].(map[string]interface{})
-	if !ok {
-		return false
+// boolArg returns the value of a boolean keyword argument
+func boolArg(args []*Arg, name string) (bool, bool) {
+	arg := findArg(args, name)
+	if arg == nil {
+		return false, false
 	}
+	return arg.Value.Bool()
+}
 
-	tracks, ok := stateMap["tracks"].([]interface{})
-	if !ok {
-		return false
+// getSelectedTrackIndex returns the index of the first selected track from state
+// Returns -1 if no selected track is found. It serves track(selected="first") and method
+// calls without a track context; track(selected=true) applies to every selected track.
+func (p *Parser) getSelectedTrackIndex() int {
+	if selected := p.tracks.SelectedTracks(); len(selected) > 0 {
+		return selected[0]
 	}
+	return -1
+}
 
-	return index < len(tracks)
+// applyStateAction applies a translated action to the working copy of the state
+// A create_track past the last track appends it. Actions that cannot run, such as notes with
+// no clip to go into, leave the copy unchanged; Apply reports them when the actions run.
+func (p *Parser) applyStateAction(action Action, index int) {
+	if p.tracks == nil {
+		return
+	}
+	if create, ok := action.(CreateTrack); ok {
+		create.Index = min(create.Index, len(p.tracks.Tracks))
+		action = create
+	}
+	_ = p.tracks.apply(action, index)
 }
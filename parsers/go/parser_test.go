@@ -1,7 +1,9 @@
-package services
+package dsl
 
 import (
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -240,18 +242,65 @@ func TestDSLParser_ParseDSL(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "track with MIDI notes",
+			dslCode: `track(instrument="Serum").newClip(bar=1, length_bars=4).addMidi(notes=[
+  {pitch=60, velocity=100, start=0, duration=1},
+  {pitch=64, velocity=100, start=1, duration=1}
+])`,
+			want: []map[string]interface{}{
+				{
+					"action":     "create_track",
+					"instrument": "Serum",
+					"index":      0,
+				},
+				{
+					"action":      "create_clip_at_bar",
+					"track":       0,
+					"bar":         1,
+					"length_bars": 4,
+				},
+				{
+					"action": "add_midi",
+					"track":  0,
+					"notes": []MidiNote{
+						{Pitch: 60, Velocity: 100, Start: 0, Duration: 1},
+						{Pitch: 64, Velocity: 100, Start: 1, Duration: 1},
+					},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name:    "empty DSL",
 			dslCode: ``,
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name:    "only comments",
+			dslCode: "# just a comment\n/* and another */",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "only whitespace",
+			dslCode: " \n\t\n",
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name:    "invalid clip without track",
 			dslCode: `.newClip(bar=3)`,
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name:    "stray text after chain",
+			dslCode: `track(instrument="Serum").newClip(bar=3) oops`,
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name:    "clip without bar or start",
 			dslCode: `track(instrument="Serum").newClip(length_bars=4)`,
@@ -262,7 +311,7 @@ func TestDSLParser_ParseDSL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parser := NewDSLParser()
+			parser := NewParser()
 			got, err := parser.ParseDSL(tt.dslCode)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ParseDSL() error = %v, wantErr %v", err, tt.wantErr)
@@ -275,132 +324,6 @@ func TestDSLParser_ParseDSL(t *testing.T) {
 	}
 }
 
-func TestDSLParser_extractParams(t *testing.T) {
-	tests := []struct {
-		name     string
-		call     string
-		want     map[string]string
-		wantKeys []string
-	}{
-		{
-			name:     "single string param",
-			call:     `track(instrument="Serum")`,
-			wantKeys: []string{"instrument"},
-		},
-		{
-			name:     "multiple params",
-			call:     `track(instrument="Serum", name="Bass", index=2)`,
-			wantKeys: []string{"instrument", "name", "index"},
-		},
-		{
-			name:     "params with spaces",
-			call:     `track( instrument = "Serum" , name = "Bass" )`,
-			wantKeys: []string{"instrument", "name"},
-		},
-		{
-			name:     "empty params",
-			call:     `track()`,
-			wantKeys: []string{},
-		},
-		{
-			name:     "numeric params",
-			call:     `newClip(bar=3, length_bars=4)`,
-			wantKeys: []string{"bar", "length_bars"},
-		},
-		{
-			name:     "boolean params",
-			call:     `setMute(mute=true)`,
-			wantKeys: []string{"mute"},
-		},
-		{
-			name:     "float params",
-			call:     `setVolume(volume_db=-3.5)`,
-			wantKeys: []string{"volume_db"},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			parser := NewDSLParser()
-			got := parser.extractParams(tt.call)
-
-			// Check that all expected keys are present
-			for _, key := range tt.wantKeys {
-				if _, ok := got[key]; !ok {
-					t.Errorf("extractParams() missing key %s, got %v", key, got)
-				}
-			}
-
-			// Check specific values for known cases
-			if tt.name == "single string param" {
-				if got["instrument"] != TestInstrumentName {
-					t.Errorf("extractParams() instrument = %v, want %s", got["instrument"], TestInstrumentName)
-				}
-			}
-			if tt.name == "multiple params" {
-				if got["instrument"] != TestInstrumentName {
-					t.Errorf("extractParams() instrument = %v, want %s", got["instrument"], TestInstrumentName)
-				}
-				if got["name"] != "Bass" {
-					t.Errorf("extractParams() name = %v, want Bass", got["name"])
-				}
-				if got["index"] != "2" {
-					t.Errorf("extractParams() index = %v, want 2", got["index"])
-				}
-			}
-		})
-	}
-}
-
-func TestDSLParser_splitMethodChains(t *testing.T) {
-	tests := []struct {
-		name      string
-		dslCode   string
-		wantLen   int
-		wantFirst string
-	}{
-		{
-			name:      "single method",
-			dslCode:   `track(instrument="Serum")`,
-			wantLen:   1,
-			wantFirst: `track(instrument="Serum")`,
-		},
-		{
-			name:      "two methods",
-			dslCode:   `track(instrument="Serum").newClip(bar=3)`,
-			wantLen:   2,
-			wantFirst: `track(instrument="Serum")`,
-		},
-		{
-			name:      "three methods",
-			dslCode:   `track(instrument="Serum").newClip(bar=3).setVolume(volume_db=-3.0)`,
-			wantLen:   3,
-			wantFirst: `track(instrument="Serum")`,
-		},
-		{
-			name:      "nested strings",
-			dslCode:   `track(instrument="VSTi: Serum (Xfer Records)").newClip(bar=3)`,
-			wantLen:   2,
-			wantFirst: `track(instrument="VSTi: Serum (Xfer Records)")`,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			parser := NewDSLParser()
-			got := parser.splitMethodChains(tt.dslCode)
-
-			if len(got) != tt.wantLen {
-				t.Errorf("splitMethodChains() len = %v, want %v", len(got), tt.wantLen)
-			}
-
-			if len(got) > 0 && got[0] != tt.wantFirst {
-				t.Errorf("splitMethodChains() first = %v, want %v", got[0], tt.wantFirst)
-			}
-		})
-	}
-}
-
 func TestDSLParser_parseTrackCall(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -448,12 +371,13 @@ func TestDSLParser_parseTrackCall(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parser := NewDSLParser()
-			got, gotIndex, err := parser.parseTrackCall(tt.call)
+			parser := NewParser()
+			action, gotIndex, err := parser.parseTrackCall(mustParseTrackCall(t, tt.call))
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseTrackCall() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			got := action.Map()
 			if got["action"] != tt.wantAction {
 				t.Errorf("parseTrackCall() action = %v, want %v", got["action"], tt.wantAction)
 			}
@@ -542,13 +466,14 @@ func TestDSLParser_parseClipCall(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parser := NewDSLParser()
-			got, err := parser.parseClipCall(tt.call, tt.trackIndex)
+			parser := NewParser()
+			action, err := parser.parseClipCall(mustParseMethodCall(t, tt.call), tt.trackIndex)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseClipCall() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
+				got := action.Map()
 				if got["action"] != tt.wantAction {
 					t.Errorf("parseClipCall() action = %v, want %v", got["action"], tt.wantAction)
 				}
@@ -600,13 +525,14 @@ func TestDSLParser_parseVolumeCall(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parser := NewDSLParser()
-			got, err := parser.parseVolumeCall(tt.call, tt.trackIndex)
+			parser := NewParser()
+			action, err := parser.parseVolumeCall(mustParseMethodCall(t, tt.call), tt.trackIndex)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseVolumeCall() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
+				got := action.Map()
 				if got["action"] != "set_track_volume" {
 					t.Errorf("parseVolumeCall() action = %v, want set_track_volume", got["action"])
 				}
@@ -651,13 +577,14 @@ func TestDSLParser_parsePanCall(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parser := NewDSLParser()
-			got, err := parser.parsePanCall(tt.call, tt.trackIndex)
+			parser := NewParser()
+			action, err := parser.parsePanCall(mustParseMethodCall(t, tt.call), tt.trackIndex)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parsePanCall() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
+				got := action.Map()
 				if got["pan"] != tt.wantPan {
 					t.Errorf("parsePanCall() pan = %v, want %v", got["pan"], tt.wantPan)
 				}
@@ -692,13 +619,14 @@ func TestDSLParser_parseMuteCall(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parser := NewDSLParser()
-			got, err := parser.parseMuteCall(tt.call, tt.trackIndex)
+			parser := NewParser()
+			action, err := parser.parseMuteCall(mustParseMethodCall(t, tt.call), tt.trackIndex)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseMuteCall() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
+				got := action.Map()
 				if got["mute"] != tt.wantMute {
 					t.Errorf("parseMuteCall() mute = %v, want %v", got["mute"], tt.wantMute)
 				}
@@ -736,13 +664,14 @@ func TestDSLParser_parseFXCall(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parser := NewDSLParser()
-			got, err := parser.parseFXCall(tt.call, tt.trackIndex)
+			parser := NewParser()
+			action, err := parser.parseFXCall(mustParseMethodCall(t, tt.call), tt.trackIndex)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseFXCall() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
+				got := action.Map()
 				if got["action"] != tt.wantAction {
 					t.Errorf("parseFXCall() action = %v, want %v", got["action"], tt.wantAction)
 				}
@@ -753,3 +682,1191 @@ func TestDSLParser_parseFXCall(t *testing.T) {
 		})
 	}
 }
+
+// mustParseTrackCall parses src as a single statement and returns its track call
+func mustParseTrackCall(t *testing.T, src string) *TrackCall {
+	t.Helper()
+	prog, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", src, err)
+	}
+	if len(prog.Statements) != 1 || prog.Statements[0].Track == nil {
+		t.Fatalf("Parse(%q) did not yield a single track call", src)
+	}
+	return prog.Statements[0].Track
+}
+
+// mustParseMethodCall parses src as a bare chain and returns its first method call
+func mustParseMethodCall(t *testing.T, src string) *MethodCall {
+	t.Helper()
+	prog, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", src, err)
+	}
+	if len(prog.Statements) != 1 || len(prog.Statements[0].Chain) == 0 {
+		t.Fatalf("Parse(%q) did not yield a method call", src)
+	}
+	return prog.Statements[0].Chain[0]
+}
+
+func TestDSLParser_parseMidiCall(t *testing.T) {
+	tests := []struct {
+		name       string
+		call       string
+		trackIndex int
+		wantErr    bool
+		wantNotes  []MidiNote
+	}{
+		{
+			name:       "single note",
+			call:       `.addMidi(notes=[{pitch=60, velocity=100, start=0, duration=1}])`,
+			trackIndex: 0,
+			wantNotes:  []MidiNote{{Pitch: 60, Velocity: 100, Start: 0, Duration: 1}},
+		},
+		{
+			name: "multiple notes with channel",
+			call: `.addMidi(notes=[
+				{pitch=60, velocity=100, start=0, duration=1},
+				{pitch=64, velocity=90, start=1.5, duration=0.5, channel=9}
+			])`,
+			trackIndex: 0,
+			wantNotes: []MidiNote{
+				{Pitch: 60, Velocity: 100, Start: 0, Duration: 1},
+				{Pitch: 64, Velocity: 90, Start: 1.5, Duration: 0.5, Channel: 9},
+			},
+		},
+		{
+			name:       "empty notes",
+			call:       `.addMidi(notes=[])`,
+			trackIndex: 0,
+			wantNotes:  []MidiNote{},
+		},
+		{
+			name:       "no track context",
+			call:       `.addMidi(notes=[])`,
+			trackIndex: -1,
+			wantErr:    true,
+		},
+		{
+			name:       "missing notes",
+			call:       `.addMidi()`,
+			trackIndex: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "notes not an array",
+			call:       `.addMidi(notes={pitch=60})`,
+			trackIndex: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "note not an object",
+			call:       `.addMidi(notes=[60])`,
+			trackIndex: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "pitch out of range",
+			call:       `.addMidi(notes=[{pitch=128, velocity=100, start=0, duration=1}])`,
+			trackIndex: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "negative velocity",
+			call:       `.addMidi(notes=[{pitch=60, velocity=-1, start=0, duration=1}])`,
+			trackIndex: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "fractional pitch",
+			call:       `.addMidi(notes=[{pitch=60.5, velocity=100, start=0, duration=1}])`,
+			trackIndex: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "negative start",
+			call:       `.addMidi(notes=[{pitch=60, velocity=100, start=-1, duration=1}])`,
+			trackIndex: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "channel out of range",
+			call:       `.addMidi(notes=[{pitch=60, velocity=100, start=0, duration=1, channel=16}])`,
+			trackIndex: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "missing duration",
+			call:       `.addMidi(notes=[{pitch=60, velocity=100, start=0}])`,
+			trackIndex: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "unknown note field",
+			call:       `.addMidi(notes=[{pitch=60, velocity=100, start=0, duration=1, pan=0}])`,
+			trackIndex: 0,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			action, err := parser.parseMidiCall(mustParseMethodCall(t, tt.call), tt.trackIndex)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseMidiCall() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				got := action.Map()
+				if got["action"] != "add_midi" {
+					t.Errorf("parseMidiCall() action = %v, want add_midi", got["action"])
+				}
+				if !reflect.DeepEqual(got["notes"], tt.wantNotes) {
+					t.Errorf("parseMidiCall() notes = %v, want %v", got["notes"], tt.wantNotes)
+				}
+			}
+		})
+	}
+}
+
+func TestDSLParser_methodSpellings(t *testing.T) {
+	camel := `track(instrument="Serum").newClip(bar=1, length_bars=4).addMidi(notes=[{pitch=60, velocity=100, start=0, duration=1}]).addFX(fxname="ReaEQ").setVolume(volume_db=-3.0).setPan(pan=0.5).setMute(mute=true).setSolo(solo=false).setName(name="Bass")`
+	snake := `track(instrument="Serum").new_clip(bar=1, length_bars=4).add_midi(notes=[{pitch=60, velocity=100, start=0, duration=1}]).add_fx(fxname="ReaEQ").set_volume(volume_db=-3.0).set_pan(pan=0.5).set_mute(mute=true).set_solo(solo=false).set_name(name="Bass")`
+
+	camelActions, err := NewParser().ParseDSL(camel)
+	if err != nil {
+		t.Fatalf("ParseDSL(camelCase) error = %v", err)
+	}
+	snakeActions, err := NewParser().ParseDSL(snake)
+	if err != nil {
+		t.Fatalf("ParseDSL(snake_case) error = %v", err)
+	}
+	if len(snakeActions) != 9 {
+		t.Errorf("ParseDSL(snake_case) got %d actions, want 9", len(snakeActions))
+	}
+	if !reflect.DeepEqual(camelActions, snakeActions) {
+		t.Errorf("ParseDSL() spellings differ:\ncamelCase  = %v\nsnake_case = %v", camelActions, snakeActions)
+	}
+}
+
+func TestDSLParser_comments(t *testing.T) {
+	commented := `# Bass line
+track(instrument="Serum", name="Bass") // create it
+  /* four bars,
+     starting at the top */
+  .newClip(bar=1, length_bars=4)
+  .setVolume(volume_db=-6) # sits under the kick`
+	plain := `track(instrument="Serum", name="Bass").newClip(bar=1, length_bars=4).setVolume(volume_db=-6)`
+
+	got, err := NewParser().ParseDSL(commented)
+	if err != nil {
+		t.Fatalf("ParseDSL(commented) error = %v", err)
+	}
+	want, err := NewParser().ParseDSL(plain)
+	if err != nil {
+		t.Fatalf("ParseDSL(plain) error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDSL() with comments = %v, want %v", got, want)
+	}
+}
+
+func TestDSLParser_canonicalMethodsOnly(t *testing.T) {
+	parser := NewParser()
+	parser.SetCanonicalMethodsOnly(true)
+
+	if _, err := parser.ParseDSL(`track().new_clip(bar=1).set_volume(volume_db=-3.0)`); err != nil {
+		t.Errorf("ParseDSL(canonical) error = %v", err)
+	}
+
+	_, err := parser.ParseDSL(`track().newClip(bar=1)`)
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("ParseDSL(alias) error = %v, want *ParseError", err)
+	}
+	if perr.Method != "newClip" || !strings.Contains(perr.Msg, `"new_clip"`) {
+		t.Errorf("ParseDSL(alias) error = %v, want suggestion of new_clip", perr)
+	}
+}
+
+func TestDSLParser_deleteAndSelect(t *testing.T) {
+	selectedState := &ProjectState{Tracks: []TrackState{{Name: "Drums"}, {Name: "Bass", Selected: true}}}
+
+	tests := []struct {
+		name    string
+		dslCode string
+		state   *ProjectState
+		want    []map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:    "delete track",
+			dslCode: `track(id=2).delete()`,
+			want: []map[string]interface{}{
+				{"action": "delete_track", "track": 1},
+			},
+		},
+		{
+			name:    "delete clip by bar",
+			dslCode: `track(1).delete_clip(bar=2)`,
+			want: []map[string]interface{}{
+				{"action": "delete_clip", "track": 0, "bar": 2},
+			},
+		},
+		{
+			name:    "delete clip by index",
+			dslCode: `track(1).deleteClip(clip=0)`,
+			want: []map[string]interface{}{
+				{"action": "delete_clip", "track": 0, "clip": 0},
+			},
+		},
+		{
+			name:    "delete clip by position",
+			dslCode: `track(1).delete_clip(position=4.5)`,
+			want: []map[string]interface{}{
+				{"action": "delete_clip", "track": 0, "position": 4.5},
+			},
+		},
+		{
+			name:    "set selected",
+			dslCode: `track(id=3).set_selected(selected=true)`,
+			want: []map[string]interface{}{
+				{"action": "set_track_selected", "track": 2, "selected": true},
+			},
+		},
+		{
+			name:    "deselect with camelCase",
+			dslCode: `track(id=3).setSelected(selected=false)`,
+			want: []map[string]interface{}{
+				{"action": "set_track_selected", "track": 2, "selected": false},
+			},
+		},
+		{
+			name:    "bare delete falls back to selected track",
+			dslCode: `.delete()`,
+			state:   selectedState,
+			want: []map[string]interface{}{
+				{"action": "delete_track", "track": 1},
+			},
+		},
+		{
+			name:    "bare delete clip falls back to selected track",
+			dslCode: `.delete_clip(bar=1)`,
+			state:   selectedState,
+			want: []map[string]interface{}{
+				{"action": "delete_clip", "track": 1, "bar": 1},
+			},
+		},
+		{
+			name:    "bare delete without selection",
+			dslCode: `.delete()`,
+			wantErr: true,
+		},
+		{
+			name:    "delete clip without target",
+			dslCode: `track(1).delete_clip()`,
+			wantErr: true,
+		},
+		{
+			name:    "delete clip with two targets",
+			dslCode: `track(1).delete_clip(bar=1, clip=0)`,
+			wantErr: true,
+		},
+		{
+			name:    "set selected without value",
+			dslCode: `track(1).set_selected()`,
+			wantErr: true,
+		},
+		{
+			name:    "method after delete",
+			dslCode: `track(1).delete().set_mute(mute=true)`,
+			wantErr: true,
+		},
+		{
+			name:    "method after delete on several tracks",
+			dslCode: `tracks(1..2).delete().set_mute(mute=true)`,
+			state:   selectedState,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			parser.SetState(tt.state)
+			got, err := parser.ParseDSL(tt.dslCode)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDSL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDSL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSLParser_unknownMethods(t *testing.T) {
+	dslCode := `track(instrument="Serum").setVolum(volume_db=-3).setPan(pan=0.5)`
+
+	_, err := NewParser().ParseDSL(dslCode)
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("ParseDSL() error = %v, want *ParseError", err)
+	}
+	want := `unknown method "setVolum", did you mean "setVolume"?`
+	if perr.Method != "setVolum" || perr.Msg != want {
+		t.Errorf("ParseDSL() error = %v, want %s", perr, want)
+	}
+	if perr.Pos.Column != 26 {
+		t.Errorf("ParseDSL() error column = %d, want 26", perr.Pos.Column)
+	}
+
+	_, err = NewParser().ParseDSL(`track().transpose(semitones=2)`)
+	if !errors.As(err, &perr) || perr.Msg != `unknown method "transpose"` {
+		t.Errorf("ParseDSL() error = %v, want unknown method without suggestion", err)
+	}
+
+	parser := NewParser()
+	parser.SetAllowUnknownMethods(true)
+	got, err := parser.ParseDSL(dslCode)
+	if err != nil {
+		t.Fatalf("ParseDSL() lenient error = %v", err)
+	}
+	wantActions := []map[string]interface{}{
+		{"action": "create_track", "instrument": "Serum", "index": 0},
+		{"action": "set_track_pan", "track": 0, "pan": 0.5},
+	}
+	if !reflect.DeepEqual(got, wantActions) {
+		t.Errorf("ParseDSL() lenient = %v, want %v", got, wantActions)
+	}
+}
+
+func TestDSLParser_trackRefs(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{
+		{Name: "Drums"},
+		{Name: "Bass"},
+		{Name: "Lead Synth"},
+		{Name: "Pad Synth"},
+	}}
+
+	tests := []struct {
+		name         string
+		dslCode      string
+		state        *ProjectState
+		resolveNames bool
+		want         []map[string]interface{}
+		wantErr      string
+	}{
+		{
+			name:    "ref by exact name",
+			dslCode: `track(ref="Bass").add_fx(fxname="ReaVerb")`,
+			state:   state,
+			want:    []map[string]interface{}{{"action": "add_track_fx", "track": 1, "fxname": "ReaVerb"}},
+		},
+		{
+			name:    "ref ignores case",
+			dslCode: `track(ref="bass").set_mute(mute=true)`,
+			state:   state,
+			want:    []map[string]interface{}{{"action": "set_track_mute", "track": 1, "mute": true}},
+		},
+		{
+			name:    "ref by word of the name",
+			dslCode: `track(ref="lead").set_volume(volume_db=-6)`,
+			state:   state,
+			want:    []map[string]interface{}{{"action": "set_track_volume", "track": 2, "volume_db": -6.0}},
+		},
+		{
+			name:    "ref with a typo",
+			dslCode: `track(ref="Drms").set_solo(solo=true)`,
+			state:   state,
+			want:    []map[string]interface{}{{"action": "set_track_solo", "track": 0, "solo": true}},
+		},
+		{
+			name:    "ambiguous ref",
+			dslCode: `track(ref="synth").set_mute(mute=true)`,
+			state:   state,
+			wantErr: `track name "synth" is ambiguous, it matches tracks 3 ("Lead Synth"), 4 ("Pad Synth")`,
+		},
+		{
+			name:    "unknown ref",
+			dslCode: `track(ref="Vocals").set_mute(mute=true)`,
+			state:   state,
+			wantErr: `no track named "Vocals" in project state`,
+		},
+		{
+			name:    "ref without state",
+			dslCode: `track(ref="Bass").set_mute(mute=true)`,
+			wantErr: `cannot resolve track "Bass": no project state set`,
+		},
+		{
+			name:    "name creates a track by default",
+			dslCode: `track(name="Bass")`,
+			state:   state,
+			want:    []map[string]interface{}{{"action": "create_track", "name": "Bass", "index": 4}},
+		},
+		{
+			name:         "name resolves to an existing track",
+			dslCode:      `track(name="BASS").add_fx(fxname="ReaVerb")`,
+			state:        state,
+			resolveNames: true,
+			want:         []map[string]interface{}{{"action": "add_track_fx", "track": 1, "fxname": "ReaVerb"}},
+		},
+		{
+			name:         "unmatched name creates a track",
+			dslCode:      `track(name="Bass 2")`,
+			state:        state,
+			resolveNames: true,
+			want:         []map[string]interface{}{{"action": "create_track", "name": "Bass 2", "index": 4}},
+		},
+		{
+			name:         "name with instrument creates a track",
+			dslCode:      `track(name="Bass", instrument="Serum")`,
+			state:        state,
+			resolveNames: true,
+			want:         []map[string]interface{}{{"action": "create_track", "name": "Bass", "instrument": "Serum", "index": 4}},
+		},
+		{
+			name:         "ambiguous name",
+			dslCode:      `track(name="Bass")`,
+			state:        &ProjectState{Tracks: []TrackState{{Name: "Bass"}, {Name: "bass"}}},
+			resolveNames: true,
+			wantErr:      `track name "Bass" is ambiguous, it matches tracks 1 ("Bass"), 2 ("bass")`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			parser.SetState(tt.state)
+			parser.SetResolveTrackNames(tt.resolveNames)
+			got, err := parser.ParseDSL(tt.dslCode)
+			if tt.wantErr != "" {
+				var perr *ParseError
+				if !errors.As(err, &perr) || perr.Msg != tt.wantErr {
+					t.Errorf("ParseDSL() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDSL() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDSL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSLParser_multipleSelectedTracks(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{
+		{Name: "Kick", Selected: true},
+		{Name: "Bass"},
+		{Name: "Snare", Selected: true},
+		{Name: "Hats", Selected: true},
+	}}
+
+	tests := []struct {
+		name    string
+		dslCode string
+		want    []map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "chain runs on every selected track",
+			dslCode: `track(selected=true).set_mute(mute=true).set_volume(volume_db=-6)`,
+			want: []map[string]interface{}{
+				{"action": "set_track_mute", "track": 0, "mute": true},
+				{"action": "set_track_volume", "track": 0, "volume_db": -6.0},
+				{"action": "set_track_mute", "track": 2, "mute": true},
+				{"action": "set_track_volume", "track": 2, "volume_db": -6.0},
+				{"action": "set_track_mute", "track": 3, "mute": true},
+				{"action": "set_track_volume", "track": 3, "volume_db": -6.0},
+			},
+		},
+		{
+			name:    "first selected track only",
+			dslCode: `track(selected="first").set_mute(mute=true)`,
+			want: []map[string]interface{}{
+				{"action": "set_track_mute", "track": 0, "mute": true},
+			},
+		},
+		{
+			name:    "deletes run from the last track",
+			dslCode: `track(selected=true).delete()`,
+			want: []map[string]interface{}{
+				{"action": "delete_track", "track": 3},
+				{"action": "delete_track", "track": 2},
+				{"action": "delete_track", "track": 0},
+			},
+		},
+		{
+			name:    "bare method uses the first selected track",
+			dslCode: `.new_clip(bar=1, length_bars=4)`,
+			want: []map[string]interface{}{
+				{"action": "create_clip_at_bar", "track": 0, "bar": 1, "length_bars": 4},
+			},
+		},
+		{
+			name:    "chain error is reported once",
+			dslCode: `track(selected=true).set_pan(pan=2)`,
+			wantErr: "pan 2 out of range -1 to 1",
+		},
+		{
+			name:    "unknown keyword",
+			dslCode: `track(selected="last").set_mute(mute=true)`,
+			wantErr: `selected must be a boolean or one of "first", got "last"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			parser.SetState(state)
+			got, err := parser.ParseDSL(tt.dslCode)
+			if tt.wantErr != "" {
+				var perr *ParseError
+				if !errors.As(err, &perr) || perr.Msg != tt.wantErr {
+					t.Errorf("ParseDSL() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDSL() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDSL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	parser := NewParser()
+	parser.SetState(state)
+	parser.SetErrorRecovery(true)
+	_, err := parser.ParseDSL(`track(selected=true).set_pan(pan=2).set_mute(mute=true)`)
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Errorf("ParseDSL() recovery error = %v, want one error", err)
+	}
+}
+
+func TestDSLParser_trackCounter(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "Drums"}, {Name: "Bass"}, {Name: "Keys"}}}
+
+	parser := NewParser()
+	if got := parser.NextTrackIndex(); got != 0 {
+		t.Errorf("NextTrackIndex() without state = %d, want 0", got)
+	}
+
+	parser.SetState(state)
+	if got := parser.NextTrackIndex(); got != 3 {
+		t.Errorf("NextTrackIndex() after SetState = %d, want 3", got)
+	}
+	got, err := parser.ParseDSL(`track(name="Lead").set_mute(mute=true)
+track(name="Pad")`)
+	if err != nil {
+		t.Fatalf("ParseDSL() error = %v", err)
+	}
+	want := []map[string]interface{}{
+		{"action": "create_track", "name": "Lead", "index": 3},
+		{"action": "set_track_mute", "track": 3, "mute": true},
+		{"action": "create_track", "name": "Pad", "index": 4},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDSL() = %v, want %v", got, want)
+	}
+
+	// The counter carries over to the next call
+	got, err = parser.ParseDSL(`track(name="Vox")`)
+	if err != nil || got[0]["index"] != 5 {
+		t.Errorf("second ParseDSL() = %v, %v, want index 5", got, err)
+	}
+
+	// Inserting before the end advances the counter by one
+	if _, err := parser.ParseDSL(`track(name="Intro", index=1)`); err != nil {
+		t.Fatalf("ParseDSL() error = %v", err)
+	}
+	if got := parser.NextTrackIndex(); got != 7 {
+		t.Errorf("NextTrackIndex() after index=1 = %d, want 7", got)
+	}
+
+	// index= past the end moves the counter after it
+	if _, err := parser.ParseDSL(`track(name="Outro", index=9)`); err != nil {
+		t.Fatalf("ParseDSL() error = %v", err)
+	}
+	if got := parser.NextTrackIndex(); got != 10 {
+		t.Errorf("NextTrackIndex() after index=9 = %d, want 10", got)
+	}
+
+	parser.ResetTrackCounter()
+	if got := parser.NextTrackIndex(); got != 3 {
+		t.Errorf("NextTrackIndex() after ResetTrackCounter = %d, want 3", got)
+	}
+	parser.SetState(nil)
+	if got := parser.NextTrackIndex(); got != 0 {
+		t.Errorf("NextTrackIndex() after SetState(nil) = %d, want 0", got)
+	}
+}
+
+// TestDSLParser_failedProgramCounters checks that a failed program leaves the counters as they were
+func TestDSLParser_failedProgramCounters(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "Drums"}, {Name: "Bass"}}}
+
+	for _, recovery := range []bool{false, true} {
+		parser := NewParser()
+		parser.SetState(state)
+		parser.SetTrackHandles(true)
+		parser.SetErrorRecovery(recovery)
+		if _, err := parser.ParseDSL(`track(name="Lead")
+track(ref="Drums").delete()
+track(name="Pad").set_pan(pan=2)`); err == nil {
+			t.Fatalf("ParseDSL() recovery=%v succeeded, want an error", recovery)
+		}
+		if got := parser.NextTrackIndex(); got != 2 {
+			t.Errorf("NextTrackIndex() recovery=%v after a failed program = %d, want 2", recovery, got)
+		}
+
+		// The next program creates the handle and index the failed one would have, and sees Drums
+		got, err := parser.ParseDSL(`track(name="Keys")
+track(ref="Drums").set_mute(mute=true)`)
+		if err != nil {
+			t.Fatalf("ParseDSL() recovery=%v error = %v", recovery, err)
+		}
+		want := []map[string]interface{}{
+			{"action": "create_track", "name": "Keys", "index": 2, "ref": "t1"},
+			{"action": "set_track_mute", "track": 0, "mute": true},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseDSL() recovery=%v = %v, want %v", recovery, got, want)
+		}
+	}
+}
+
+// TestDSLParser_trackCounterEdits checks that inserts and deletes keep new tracks at the end
+func TestDSLParser_trackCounterEdits(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "Drums"}, {Name: "Bass"}, {Name: "Keys"}}}
+
+	tests := []struct {
+		name    string
+		dslCode string
+		want    []map[string]interface{}
+	}{
+		{
+			name: "insert then append",
+			dslCode: `track(name="Intro", index=0)
+track(name="Lead")`,
+			want: []map[string]interface{}{
+				{"action": "create_track", "name": "Intro", "index": 0},
+				{"action": "create_track", "name": "Lead", "index": 4},
+			},
+		},
+		{
+			name: "delete then append",
+			dslCode: `track(2).delete()
+track(name="Lead")`,
+			want: []map[string]interface{}{
+				{"action": "delete_track", "track": 1},
+				{"action": "create_track", "name": "Lead", "index": 2},
+			},
+		},
+		{
+			name: "delete a created track then append",
+			dslCode: `track(name="Scratch").delete()
+track(3).delete()
+track(name="Lead")`,
+			want: []map[string]interface{}{
+				{"action": "create_track", "name": "Scratch", "index": 3},
+				{"action": "delete_track", "track": 3},
+				{"action": "delete_track", "track": 2},
+				{"action": "create_track", "name": "Lead", "index": 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			parser.SetState(state)
+			got, err := parser.ParseDSL(tt.dslCode)
+			if err != nil {
+				t.Fatalf("ParseDSL() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDSL() = %v, want %v", got, tt.want)
+			}
+
+			// The actions apply cleanly, with the new track last
+			parser = NewParser()
+			parser.SetState(state)
+			actions, err := parser.ParseProgram(tt.dslCode)
+			if err != nil {
+				t.Fatalf("ParseProgram() error = %v", err)
+			}
+			applied, err := Apply(state, actions)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if last := applied.Tracks[len(applied.Tracks)-1]; last.Name != "Lead" {
+				t.Errorf("Apply() last track = %q, want Lead", last.Name)
+			}
+		})
+	}
+}
+
+// TestDSLParser_referencesAfterEdits checks that references see earlier creates and deletes
+func TestDSLParser_referencesAfterEdits(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "A"}, {Name: "B"}, {Name: "C", Selected: true}, {Name: "D", Selected: true}}}
+
+	tests := []struct {
+		name         string
+		dslCode      string
+		resolveNames bool
+		want         []int // Tracks of the set_mute actions
+	}{
+		{
+			name: "ref after delete",
+			dslCode: `track(ref="C").delete()
+track(ref="D").set_mute(mute=true)`,
+			want: []int{2},
+		},
+		{
+			name: "ref after insert",
+			dslCode: `track(name="Intro", index=0)
+track(ref="B").set_mute(mute=true)`,
+			want: []int{2},
+		},
+		{
+			name: "ref to a created track",
+			dslCode: `track(name="Lead")
+track(ref="Lead").set_mute(mute=true)`,
+			want: []int{4},
+		},
+		{
+			name: "name after delete",
+			dslCode: `track(1).delete()
+track(name="D").set_mute(mute=true)`,
+			resolveNames: true,
+			want:         []int{2},
+		},
+		{
+			name: "selector after delete",
+			dslCode: `track(ref="A").delete()
+tracks(2..3).set_mute(mute=true)`,
+			want: []int{1, 2},
+		},
+		{
+			name: "selector after insert",
+			dslCode: `track(name="Intro", index=0)
+tracks(name="D").set_mute(mute=true)`,
+			want: []int{4},
+		},
+		{
+			name: "selected tracks after insert",
+			dslCode: `track(name="Intro", index=1)
+track(selected=true).set_mute(mute=true)`,
+			want: []int{3, 4},
+		},
+		{
+			name: "ref after rename",
+			dslCode: `track(ref="B").set_name(name="Bass")
+track(ref="Bass").set_mute(mute=true)`,
+			want: []int{1},
+		},
+		{
+			name: "selected tracks after selecting",
+			dslCode: `track(ref="A").set_selected(selected=true)
+track(selected=true).set_mute(mute=true)`,
+			want: []int{0, 2, 3},
+		},
+		{
+			name: "first selected track after delete",
+			dslCode: `track(ref="C").delete()
+track(selected="first").set_mute(mute=true)`,
+			want: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			parser.SetState(state)
+			parser.SetResolveTrackNames(tt.resolveNames)
+			actions, err := parser.ParseProgram(tt.dslCode)
+			if err != nil {
+				t.Fatalf("ParseProgram() error = %v", err)
+			}
+			var got []int
+			for _, action := range actions {
+				if mute, ok := action.(SetTrackMute); ok {
+					got = append(got, mute.Track)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseProgram() muted tracks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The bare-method fallback follows the selection too, also in a later program
+	parser := NewParser()
+	parser.SetState(state)
+	if _, err := parser.ParseDSL(`track(ref="C").delete()`); err != nil {
+		t.Fatalf("ParseDSL() error = %v", err)
+	}
+	if got, err := parser.ParseDSL(`.new_clip(bar=1)`); err != nil || got[0]["track"] != 2 {
+		t.Errorf("ParseDSL() = %v, %v, want a clip on track 2", got, err)
+	}
+
+	// ResetTrackCounter goes back to the state as it was set
+	parser.ResetTrackCounter()
+	if got, err := parser.ParseDSL(`track(ref="C").set_mute(mute=true)`); err != nil || got[0]["track"] != 2 {
+		t.Errorf("ParseDSL() after ResetTrackCounter = %v, %v, want track 2", got, err)
+	}
+}
+
+func TestDSLParser_trackHandles(t *testing.T) {
+	parser := NewParser()
+	parser.SetTrackHandles(true)
+	got, err := parser.ParseDSL(`track(name="Bass").new_clip(bar=1)
+track(name="Drums") as drums
+track(id=1).set_mute(mute=true)
+drums.add_fx(fxname="ReaComp").set_volume(volume_db=-3)`)
+	if err != nil {
+		t.Fatalf("ParseDSL() error = %v", err)
+	}
+	want := []map[string]interface{}{
+		{"action": "create_track", "name": "Bass", "index": 0, "ref": "t1"},
+		{"action": "create_clip_at_bar", "track": 0, "track_ref": "t1", "bar": 1, "length_bars": 4},
+		{"action": "create_track", "name": "Drums", "index": 1, "ref": "t2"},
+		{"action": "set_track_mute", "track": 0, "mute": true},
+		{"action": "add_track_fx", "track": 1, "track_ref": "t2", "fxname": "ReaComp"},
+		{"action": "set_track_volume", "track": 1, "track_ref": "t2", "volume_db": -3.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDSL() = %v, want %v", got, want)
+	}
+
+	// Handles stay unique across calls
+	got, err = parser.ParseDSL(`track(name="Keys")`)
+	if err != nil || got[0]["ref"] != "t3" {
+		t.Errorf("second ParseDSL() = %v, %v, want ref t3", got, err)
+	}
+}
+
+func TestDSLParser_bindings(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "Drums"}, {Name: "Bass"}, {Name: "Keys", Selected: true}}}
+
+	tests := []struct {
+		name string
+		dsl  string
+		want []map[string]interface{}
+	}{
+		{
+			name: "bound created track",
+			dsl: `track(name="Lead") as lead
+lead.set_pan(pan=-0.5)`,
+			want: []map[string]interface{}{
+				{"action": "create_track", "name": "Lead", "index": 3},
+				{"action": "set_track_pan", "track": 3, "pan": -0.5},
+			},
+		},
+		{
+			name: "bound reference",
+			dsl: `track(ref="bass") as bass
+bass.set_mute(mute=true)`,
+			want: []map[string]interface{}{
+				{"action": "set_track_mute", "track": 1, "mute": true},
+			},
+		},
+		{
+			name: "rebinding replaces the binding",
+			dsl: `track(1) as t
+track(2) as t
+t.set_solo(solo=true)`,
+			want: []map[string]interface{}{
+				{"action": "set_track_solo", "track": 1, "solo": true},
+			},
+		},
+		{
+			name: "deleting a track shifts later bindings",
+			dsl: `track(3) as keys
+track(1).delete()
+keys.set_mute(mute=true)`,
+			want: []map[string]interface{}{
+				{"action": "delete_track", "track": 0},
+				{"action": "set_track_mute", "track": 1, "mute": true},
+			},
+		},
+		{
+			name: "inserting a track shifts later bindings",
+			dsl: `track(name="A") as a
+track(2) as bass
+track(index=0, name="B")
+a.set_mute(mute=true)
+bass.set_solo(solo=true)`,
+			want: []map[string]interface{}{
+				{"action": "create_track", "name": "A", "index": 3},
+				{"action": "create_track", "name": "B", "index": 0},
+				{"action": "set_track_mute", "track": 4, "mute": true},
+				{"action": "set_track_solo", "track": 2, "solo": true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			parser.SetState(state)
+			got, err := parser.ParseDSL(tt.dsl)
+			if err != nil {
+				t.Fatalf("ParseDSL() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDSL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSLParser_bindingErrors(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "Drums"}, {Name: "Bass"}}}
+
+	tests := []struct {
+		name string
+		dsl  string
+		want string
+	}{
+		{
+			name: "unknown name",
+			dsl:  `drums.set_mute(mute=true)`,
+			want: `unknown name "drums", bind it first with let drums = ... or track(...) as drums`,
+		},
+		{
+			name: "misspelled name",
+			dsl: `track(1) as drums
+drum.set_mute(mute=true)`,
+			want: `unknown name "drum", did you mean "drums"?`,
+		},
+		{
+			name: "deleted track",
+			dsl: `track(2) as bass
+bass.delete()
+bass.set_mute(mute=true)`,
+			want: "bass refers to a deleted track",
+		},
+		{
+			name: "several tracks",
+			dsl:  `track(selected=true) as keys`,
+			want: "cannot bind keys to track(selected=true), it matches 2 tracks",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			selected := state.Copy()
+			selected.Tracks[0].Selected, selected.Tracks[1].Selected = true, true
+			parser.SetState(selected)
+			_, err := parser.ParseDSL(tt.dsl)
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("ParseDSL() error = %v, want *ParseError", err)
+			}
+			if perr.Msg != tt.want {
+				t.Errorf("error = %q, want %q", perr.Msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSLParser_letBindings(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "Drums"}, {Name: "Bass"}}}
+
+	tests := []struct {
+		name string
+		dsl  string
+		want []map[string]interface{}
+	}{
+		{
+			name: "track and value",
+			dsl: `let lead = track(instrument="Serum", name="Lead")
+let bars = 8
+lead.newClip(bar=1, length_bars=bars)`,
+			want: []map[string]interface{}{
+				{"action": "create_track", "instrument": "Serum", "name": "Lead", "index": 2},
+				{"action": "create_clip_at_bar", "track": 2, "bar": 1, "length_bars": 8},
+			},
+		},
+		{
+			name: "let with a chain",
+			dsl: `let lead = track(name="Lead").set_mute(mute=true)
+lead.set_solo(solo=true)`,
+			want: []map[string]interface{}{
+				{"action": "create_track", "name": "Lead", "index": 2},
+				{"action": "set_track_mute", "track": 2, "mute": true},
+				{"action": "set_track_solo", "track": 2, "solo": true},
+			},
+		},
+		{
+			name: "values in notes and track arguments",
+			dsl: `let root = 40
+let title = "Sub"
+track(name=title).add_midi(notes=[{pitch=root, velocity=100, start=0, duration=1}])`,
+			want: []map[string]interface{}{
+				{"action": "create_track", "name": "Sub", "index": 2},
+				{"action": "add_midi", "track": 2, "notes": []MidiNote{{Pitch: 40, Velocity: 100, Start: 0, Duration: 1}}},
+			},
+		},
+		{
+			name: "aliases",
+			dsl: `let bass = track(2)
+let low = bass
+let quiet = -6
+let level = quiet
+low.set_volume(volume_db=level)`,
+			want: []map[string]interface{}{
+				{"action": "set_track_volume", "track": 1, "volume_db": -6.0},
+			},
+		},
+		{
+			name: "array value",
+			dsl: `let riff = [{pitch=60, velocity=90, start=0, duration=0.5}]
+track(1).add_midi(notes=riff)`,
+			want: []map[string]interface{}{
+				{"action": "add_midi", "track": 0, "notes": []MidiNote{{Pitch: 60, Velocity: 90, Start: 0, Duration: 0.5}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			parser.SetState(state)
+			got, err := parser.ParseDSL(tt.dsl)
+			if err != nil {
+				t.Fatalf("ParseDSL() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDSL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSLParser_letScope(t *testing.T) {
+	parser := NewParser()
+
+	// A program of bindings only produces no actions and no error
+	got, err := parser.ParseDSL(`let bars = 4`)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("ParseDSL(let) = %v, %v, want no actions", got, err)
+	}
+
+	// Bindings carry over to the next call, and rebinding replaces them
+	got, err = parser.ParseDSL(`track().new_clip(bar=1, length_bars=bars)
+let bars = 2
+track(1).new_clip(bar=5, length_bars=bars)`)
+	if err != nil {
+		t.Fatalf("ParseDSL() error = %v", err)
+	}
+	if got[1]["length_bars"] != 4 || got[2]["length_bars"] != 2 {
+		t.Errorf("ParseDSL() = %v, want length_bars 4 then 2", got)
+	}
+
+	// SetState and ResetBindings forget every name
+	parser.SetState(&ProjectState{Tracks: []TrackState{{Name: "Bass"}}})
+	if _, err := parser.ParseDSL(`track().new_clip(bar=1, length_bars=bars)`); err == nil {
+		t.Errorf("ParseDSL() after SetState succeeded, want unknown name bars")
+	}
+	if _, err := parser.ParseDSL(`let bars = 8`); err != nil {
+		t.Fatalf("ParseDSL() error = %v", err)
+	}
+	parser.ResetBindings()
+	if _, err := parser.ParseDSL(`track().new_clip(bar=1, length_bars=bars)`); err == nil {
+		t.Errorf("ParseDSL() after ResetBindings succeeded, want unknown name bars")
+	}
+}
+
+// TestDSLParser_failedProgramBindings checks that a failed program leaves no bindings behind
+func TestDSLParser_failedProgramBindings(t *testing.T) {
+	for _, recovery := range []bool{false, true} {
+		parser := NewParser()
+		parser.SetState(&ProjectState{Tracks: []TrackState{{Name: "Drums"}, {Name: "Bass"}}})
+		parser.SetErrorRecovery(recovery)
+		if _, err := parser.ParseDSL(`let bars = 4
+track(ref="Bass") as bass`); err != nil {
+			t.Fatalf("ParseDSL() recovery=%v error = %v", recovery, err)
+		}
+		if _, err := parser.ParseDSL(`track(ref="Drums").delete()
+track(name="Lead") as lead
+let bars = 8
+lead.set_pan(pan=2)`); err == nil {
+			t.Fatalf("ParseDSL() recovery=%v succeeded, want an error", recovery)
+		}
+
+		// lead is gone, bars is still 4 and bass is still track 2, since Drums was not deleted
+		if _, err := parser.ParseDSL(`lead.set_mute(mute=true)`); err == nil {
+			t.Errorf("ParseDSL() recovery=%v: lead is still bound after a failed program", recovery)
+		}
+		got, err := parser.ParseDSL(`bass.new_clip(bar=1, length_bars=bars)`)
+		if err != nil {
+			t.Fatalf("ParseDSL() recovery=%v error = %v", recovery, err)
+		}
+		want := []map[string]interface{}{{"action": "create_clip_at_bar", "track": 1, "bar": 1, "length_bars": 4}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseDSL() recovery=%v = %v, want %v", recovery, got, want)
+		}
+	}
+}
+
+func TestDSLParser_letErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dsl  string
+		want string
+	}{
+		{
+			name: "unknown value",
+			dsl:  `track().set_volume(volume_db=loud)`,
+			want: `unknown name "loud", bind it first with let loud = ... or track(...) as loud`,
+		},
+		{
+			name: "misspelled value",
+			dsl: `let bars = 8
+track().new_clip(bar=1, length_bars=bar)`,
+			want: `unknown name "bar", did you mean "bars"?`,
+		},
+		{
+			name: "value of the wrong type",
+			dsl: `let loud = "very"
+track().set_volume(volume_db=loud)`,
+			want: "volume_db must be a number, got string",
+		},
+		{
+			name: "track used as a value",
+			dsl: `let bass = track()
+track().set_volume(volume_db=bass)`,
+			want: "bass is a track, not a value",
+		},
+		{
+			name: "value used as a track",
+			dsl: `let bars = 8
+bars.set_mute(mute=true)`,
+			want: "bars is a value, not a track",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewParser().ParseDSL(tt.dsl)
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("ParseDSL() error = %v, want *ParseError", err)
+			}
+			if perr.Msg != tt.want {
+				t.Errorf("error = %q, want %q", perr.Msg, tt.want)
+			}
+		})
+	}
+}
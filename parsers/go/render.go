@@ -0,0 +1,326 @@
+package dsl
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Render converts actions back into DSL code, one statement per line
+// Consecutive actions on the same track share one chain, which ends at a delete_track: a
+// create_track action starts track(..., index=N), and any other track starts track(id=N).
+// Methods use their canonical spellings. Parsing the result with a new Parser reproduces the actions.
+//
+// Actions with track handles, as produced with SetTrackHandles, render each created track as
+// track(...) as <ref> and chain actions with a track_ref on the handle. Parsing them back needs a
+// new Parser with handles enabled, so the refs must be the ones it generates: t1, t2, ... in
+// order of creation, each naming the track it does after earlier inserts and deletes.
+//
+// Example:
+//
+//	Render([]Action{SetTrackVolume{Track: 0, VolumeDB: -3}, SetTrackMute{Track: 0, Mute: true}})
+//	// track(id=1).set_volume(volume_db=-3).set_mute(mute=true)
+func Render(actions []Action) (string, error) {
+	prog, err := actionsProgram(actions)
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, len(prog.Statements))
+	for i, stmt := range prog.Statements {
+		lines[i] = stmt.String()
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// actionsProgram builds the AST that Render prints, validating every call on the way
+func actionsProgram(actions []Action) (*Program, error) {
+	prog := &Program{}
+	var current *Statement
+	currentTrack, currentRef := -1, ""
+	handles := renderHandles{}
+	handleMode := slices.ContainsFunc(actions, func(action Action) bool {
+		create, ok := action.(CreateTrack)
+		return (ok && create.Ref != "") || actionTrackRef(action) != ""
+	})
+	created := 0
+
+	for i, action := range actions {
+		if create, ok := action.(CreateTrack); ok {
+			call, err := renderTrackCall(create)
+			if err != nil {
+				return nil, renderError(i, action, err)
+			}
+			created++
+			if want := fmt.Sprintf("t%d", created); handleMode && create.Ref != want {
+				return nil, renderError(i, action, fmt.Errorf("ref must be %q, the handle a new parser generates for this track, got %q", want, create.Ref))
+			}
+			handles.insert(create.Index)
+			current = &Statement{Track: call}
+			if create.Ref != "" {
+				handles[create.Ref] = create.Index
+				current.Bind = &Ident{Name: create.Ref}
+			}
+			currentTrack, currentRef = create.Index, create.Ref
+			prog.Statements = append(prog.Statements, current)
+			continue
+		}
+
+		track, call, err := renderMethodCall(action)
+		if err != nil {
+			return nil, renderError(i, action, err)
+		}
+		ref := actionTrackRef(action)
+		if ref != "" {
+			if err := handles.check(ref, track); err != nil {
+				return nil, renderError(i, action, err)
+			}
+		}
+		if current == nil || track != currentTrack || ref != currentRef {
+			if ref != "" {
+				current = &Statement{Target: &Ident{Name: ref}}
+			} else {
+				current = &Statement{Track: &TrackCall{Args: []*Arg{intArgNode("id", track+1)}}}
+			}
+			currentTrack, currentRef = track, ref
+			prog.Statements = append(prog.Statements, current)
+		}
+		current.Chain = append(current.Chain, call)
+		if _, ok := action.(DeleteTrack); ok {
+			// Nothing can follow .delete() in a chain
+			handles.delete(track)
+			current = nil
+		}
+	}
+	return prog, nil
+}
+
+// renderHandles maps each handle to the index of its track, or -1 once the track is deleted
+// It follows inserts and deletes the same way the parser's bindings do.
+type renderHandles map[string]int
+
+func (h renderHandles) insert(index int) {
+	for ref, track := range h {
+		if track >= index {
+			h[ref] = track + 1
+		}
+	}
+}
+
+func (h renderHandles) delete(index int) {
+	for ref, track := range h {
+		switch {
+		case track == index:
+			h[ref] = -1
+		case track > index:
+			h[ref] = track - 1
+		}
+	}
+}
+
+// check reports whether ref names track
+func (h renderHandles) check(ref string, track int) error {
+	index, ok := h[ref]
+	switch {
+	case !ok:
+		return fmt.Errorf("track_ref %q is not the ref of a track created earlier", ref)
+	case index < 0:
+		return fmt.Errorf("track_ref %q refers to a deleted track", ref)
+	case index != track:
+		return fmt.Errorf("track_ref %q is track %d, not %d", ref, index, track)
+	}
+	return nil
+}
+
+func renderError(index int, action Action, err error) error {
+	msg := err.Error()
+	if perr, ok := err.(*ParseError); ok {
+		msg = perr.Msg
+	}
+	return fmt.Errorf("render action %d (%s): %s", index, action.Type(), msg)
+}
+
+// renderTrackCall builds track(instrument=..., name=..., index=N) for a create_track action
+// The index is always explicit so the statement does not depend on the parser's track counter.
+func renderTrackCall(a CreateTrack) (*TrackCall, error) {
+	var args []*Arg
+	if a.Instrument != "" {
+		args = append(args, stringArgNode("instrument", a.Instrument))
+	}
+	if a.Name != "" {
+		args = append(args, stringArgNode("name", a.Name))
+	}
+	args = append(args, intArgNode("index", a.Index))
+
+	call := &TrackCall{Args: args}
+	if err := validateTrackArgs(call); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// renderMethodCall builds the method call for a track action and returns its track index
+//
+//nolint:gocyclo // One case per action type
+func renderMethodCall(action Action) (int, *MethodCall, error) {
+	var track int
+	var method string
+	var args []*Arg
+
+	switch a := action.(type) {
+	case CreateClipAtBar:
+		track, method = a.Track, "new_clip"
+		args = []*Arg{intArgNode("bar", a.Bar), intArgNode("length_bars", a.LengthBars)}
+	case CreateClip:
+		track, method = a.Track, "new_clip"
+		args = []*Arg{numberArgNode("start", a.Position), numberArgNode("length", a.Length)}
+	case AddMidi:
+		track, method = a.Track, "add_midi"
+		notes := &Literal{Kind: ArrayLiteral}
+		for _, note := range a.Notes {
+			notes.Elems = append(notes.Elems, noteLiteral(note))
+		}
+		args = []*Arg{{Name: "notes", Value: notes}}
+	case AddTrackFX:
+		track, method = a.Track, "add_fx"
+		args = []*Arg{stringArgNode("fxname", a.FXName)}
+	case AddInstrument:
+		track, method = a.Track, "add_fx"
+		args = []*Arg{stringArgNode("instrument", a.FXName)}
+	case SetTrackVolume:
+		track, method = a.Track, "set_volume"
+		args = []*Arg{numberArgNode("volume_db", a.VolumeDB)}
+	case SetTrackPan:
+		track, method = a.Track, "set_pan"
+		args = []*Arg{numberArgNode("pan", a.Pan)}
+	case SetTrackMute:
+		track, method = a.Track, "set_mute"
+		args = []*Arg{boolArgNode("mute", a.Mute)}
+	case SetTrackSolo:
+		track, method = a.Track, "set_solo"
+		args = []*Arg{boolArgNode("solo", a.Solo)}
+	case SetTrackName:
+		track, method = a.Track, "set_name"
+		args = []*Arg{stringArgNode("name", a.Name)}
+	case SetTrackSelected:
+		track, method = a.Track, "set_selected"
+		args = []*Arg{boolArgNode("selected", a.Selected)}
+	case DeleteTrack:
+		track, method = a.Track, "delete"
+	case DeleteClip:
+		track, method = a.Track, "delete_clip"
+		if a.Clip != nil {
+			args = append(args, intArgNode("clip", *a.Clip))
+		}
+		if a.Bar != nil {
+			args = append(args, intArgNode("bar", *a.Bar))
+		}
+		if a.Position != nil {
+			args = append(args, numberArgNode("position", *a.Position))
+		}
+		if len(args) != 1 {
+			return 0, nil, fmt.Errorf("delete_clip needs exactly one of clip, bar or position")
+		}
+	default:
+		return 0, nil, fmt.Errorf("cannot render action type %T", action)
+	}
+
+	if track < 0 {
+		return 0, nil, fmt.Errorf("track index must not be negative, got %d", track)
+	}
+	call := &MethodCall{Name: method, Args: args}
+	if err := validateRenderedCall(call); err != nil {
+		return 0, nil, err
+	}
+	return track, call, nil
+}
+
+// validateRenderedCall checks a built call against the method table, as the parser would
+func validateRenderedCall(call *MethodCall) error {
+	def, _ := lookupMethod(call.Name)
+	for _, arg := range call.Args {
+		if err := checkFinite(arg.Value); err != nil {
+			return err
+		}
+	}
+	if err := validateArgs(call.Name, call.Pos, call.Args, def.params); err != nil {
+		return err
+	}
+	if notes := call.Arg("notes"); notes != nil {
+		for i, note := range notes.Value.Elems {
+			if err := validateArgs(fmt.Sprintf("notes[%d]", i), note.Pos, note.Fields, noteParams); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkFinite rejects NaN and infinite numbers, which have no DSL spelling
+func checkFinite(lit *Literal) error {
+	for _, elem := range lit.Elems {
+		if err := checkFinite(elem); err != nil {
+			return err
+		}
+	}
+	for _, field := range lit.Fields {
+		if err := checkFinite(field.Value); err != nil {
+			return err
+		}
+	}
+	if lit.Kind == NumberLiteral && !isNumberText(lit.Text) {
+		return fmt.Errorf("%s is not a finite number", lit.Text)
+	}
+	return nil
+}
+
+// isNumberText reports whether s matches the DSL number syntax -?\d+(\.\d+)?
+func isNumberText(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	return allDigits(whole) && (!hasFrac || allDigits(frac))
+}
+
+func allDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func noteLiteral(note MidiNote) *Literal {
+	fields := []*Arg{
+		intArgNode("pitch", note.Pitch),
+		intArgNode("velocity", note.Velocity),
+		numberArgNode("start", note.Start),
+		numberArgNode("duration", note.Duration),
+	}
+	if note.Channel != 0 {
+		fields = append(fields, intArgNode("channel", note.Channel))
+	}
+	return &Literal{Kind: ObjectLiteral, Fields: fields}
+}
+
+// AST node constructors
+
+func stringArgNode(name, value string) *Arg {
+	return &Arg{Name: name, Value: &Literal{Kind: StringLiteral, Text: value}}
+}
+
+func intArgNode(name string, value int) *Arg {
+	return &Arg{Name: name, Value: &Literal{Kind: NumberLiteral, Text: strconv.Itoa(value)}}
+}
+
+func numberArgNode(name string, value float64) *Arg {
+	// NaN and infinities format as "NaN" and "+Inf", which checkFinite rejects
+	return &Arg{Name: name, Value: &Literal{Kind: NumberLiteral, Text: strconv.FormatFloat(value, 'f', -1, 64)}}
+}
+
+func boolArgNode(name string, value bool) *Arg {
+	return &Arg{Name: name, Value: &Literal{Kind: BoolLiteral, Text: strconv.FormatBool(value)}}
+}
@@ -0,0 +1,253 @@
+package dsl
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	bar, position := 2, 4.5
+	tests := []struct {
+		name    string
+		actions []Action
+		want    string
+	}{
+		{
+			name:    "empty",
+			actions: nil,
+			want:    "",
+		},
+		{
+			name: "groups consecutive actions on one track",
+			actions: []Action{
+				SetTrackVolume{Track: 0, VolumeDB: -3},
+				SetTrackPan{Track: 0, Pan: 0.5},
+				SetTrackMute{Track: 1, Mute: true},
+				SetTrackSolo{Track: 0, Solo: false},
+			},
+			want: `track(id=1).set_volume(volume_db=-3).set_pan(pan=0.5)
+track(id=2).set_mute(mute=true)
+track(id=1).set_solo(solo=false)`,
+		},
+		{
+			name: "create track starts a chain",
+			actions: []Action{
+				CreateTrack{Instrument: "Serum", Name: "Bass", Index: 0},
+				CreateClipAtBar{Track: 0, Bar: 1, LengthBars: 4},
+				AddMidi{Track: 0, Notes: []MidiNote{{Pitch: 36, Velocity: 100, Start: 0, Duration: 0.5, Channel: 9}}},
+				AddTrackFX{Track: 0, FXName: "ReaEQ"},
+				CreateTrack{Index: 1},
+				AddInstrument{Track: 1, FXName: "Massive"},
+			},
+			want: `track(instrument="Serum", name="Bass", index=0).new_clip(bar=1, length_bars=4).add_midi(notes=[{pitch=36, velocity=100, start=0, duration=0.5, channel=9}]).add_fx(fxname="ReaEQ")
+track(index=1).add_fx(instrument="Massive")`,
+		},
+		{
+			name: "clips, names and deletion",
+			actions: []Action{
+				CreateClip{Track: 2, Position: 1.5, Length: 8},
+				SetTrackName{Track: 2, Name: `Say "hi"\now`},
+				SetTrackSelected{Track: 2, Selected: true},
+				DeleteClip{Track: 2, Bar: &bar},
+				DeleteClip{Track: 2, Position: &position},
+				DeleteTrack{Track: 2},
+			},
+			want: `track(id=3).new_clip(start=1.5, length=8).set_name(name="Say \"hi\"\\now").set_selected(selected=true).delete_clip(bar=2).delete_clip(position=4.5).delete()`,
+		},
+		{
+			name: "delete ends a chain",
+			actions: []Action{
+				DeleteTrack{Track: 0},
+				SetTrackMute{Track: 0, Mute: true},
+			},
+			want: `track(id=1).delete()
+track(id=1).set_mute(mute=true)`,
+		},
+		{
+			name: "track handles",
+			actions: []Action{
+				CreateTrack{Name: "A", Index: 0, Ref: "t1"},
+				SetTrackMute{Track: 0, Mute: true, TrackRef: "t1"},
+				CreateTrack{Name: "B", Index: 0, Ref: "t2"},
+				SetTrackPan{Track: 1, Pan: 0.5, TrackRef: "t1"},
+				SetTrackSolo{Track: 1, Solo: true},
+			},
+			want: `track(name="A", index=0) as t1.set_mute(mute=true)
+track(name="B", index=0) as t2
+t1.set_pan(pan=0.5)
+track(id=2).set_solo(solo=true)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.actions)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender_errors(t *testing.T) {
+	clip, bar := 0, 1
+	tests := []struct {
+		name   string
+		action Action
+		want   string
+	}{
+		{"negative track", SetTrackMute{Track: -1}, "track index must not be negative"},
+		{"negative index", CreateTrack{Index: -1}, "index -1 out of range"},
+		{"volume out of range", SetTrackVolume{VolumeDB: 30}, "volume_db 30 out of range"},
+		{"NaN", SetTrackPan{Pan: math.NaN()}, "NaN is not a finite number"},
+		{"infinity", CreateClip{Position: 0, Length: math.Inf(1)}, "+Inf is not a finite number"},
+		{"bad note", AddMidi{Notes: []MidiNote{{Pitch: 128, Velocity: 1, Duration: 1}}}, "pitch 128 out of range"},
+		{"delete_clip without target", DeleteClip{}, "exactly one of clip, bar or position"},
+		{"delete_clip with two targets", DeleteClip{Clip: &clip, Bar: &bar}, "exactly one of clip, bar or position"},
+		{"unknown track_ref", SetTrackPan{Track: 0, TrackRef: "t1"}, `track_ref "t1" is not the ref of a track created earlier`},
+		{"ref out of order", CreateTrack{Index: 0, Ref: "t2"}, `ref must be "t1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Render([]Action{SetTrackMute{Track: 0}, tt.action})
+			if err == nil {
+				t.Fatalf("Render() error = nil, want %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), "render action 1 ("+tt.action.Type()+")") {
+				t.Errorf("Render() error = %q, want it to name action 1 and contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestRender_roundTrip checks that ParseDSL(Render(a)) == a for random action lists
+func TestRender_roundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		actions := randomActions(rng)
+		src, err := Render(actions)
+		if err != nil {
+			t.Fatalf("Render(%#v) error = %v", actions, err)
+		}
+
+		got, err := NewParser().ParseDSL(src)
+		if err != nil {
+			t.Fatalf("ParseDSL(Render(a)) error = %v\n%s", err, src)
+		}
+		if want := ActionMaps(actions); !reflect.DeepEqual(got, want) {
+			t.Fatalf("ParseDSL(Render(a)) = %v\nwant %v\nsource:\n%s", got, want, src)
+		}
+	}
+}
+
+// TestRender_roundTripHandles checks the round trip for actions parsed with track handles
+func TestRender_roundTripHandles(t *testing.T) {
+	handleParser := func() *Parser {
+		parser := NewParser()
+		parser.SetTrackHandles(true)
+		return parser
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		src, err := Render(randomActions(rng))
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		actions, err := handleParser().ParseProgram(src)
+		if err != nil {
+			t.Fatalf("ParseProgram() error = %v\n%s", err, src)
+		}
+
+		src, err = Render(actions)
+		if err != nil {
+			t.Fatalf("Render(%#v) error = %v", actions, err)
+		}
+		got, err := handleParser().ParseProgram(src)
+		if err != nil {
+			t.Fatalf("ParseProgram(Render(a)) error = %v\n%s", err, src)
+		}
+		if !reflect.DeepEqual(got, actions) {
+			t.Fatalf("ParseProgram(Render(a)) = %v\nwant %v\nsource:\n%s", got, actions, src)
+		}
+	}
+}
+
+func randomActions(rng *rand.Rand) []Action {
+	track := func() int { return rng.Intn(4) }
+	number := func(min, max float64) float64 {
+		if rng.Intn(3) == 0 {
+			return math.Round(min + rng.Float64()*(max-min))
+		}
+		return min + rng.Float64()*(max-min)
+	}
+	generators := []func() Action{
+		func() Action {
+			return CreateTrack{Instrument: randomString(rng), Name: randomString(rng), Index: rng.Intn(6)}
+		},
+		func() Action {
+			return CreateClipAtBar{Track: track(), Bar: 1 + rng.Intn(64), LengthBars: 1 + rng.Intn(16)}
+		},
+		func() Action { return CreateClip{Track: track(), Position: number(0, 100), Length: number(0, 32)} },
+		func() Action {
+			notes := make([]MidiNote, rng.Intn(4))
+			for i := range notes {
+				notes[i] = MidiNote{
+					Pitch:    rng.Intn(MaxMidiValue + 1),
+					Velocity: rng.Intn(MaxMidiValue + 1),
+					Start:    number(0, 16),
+					Duration: number(0, 4),
+					Channel:  rng.Intn(MaxMidiChannel + 1),
+				}
+			}
+			return AddMidi{Track: track(), Notes: notes}
+		},
+		func() Action { return AddTrackFX{Track: track(), FXName: randomString(rng)} },
+		func() Action { return AddInstrument{Track: track(), FXName: randomString(rng)} },
+		func() Action { return SetTrackVolume{Track: track(), VolumeDB: number(MinVolumeDB, MaxVolumeDB)} },
+		func() Action { return SetTrackPan{Track: track(), Pan: number(MinPan, MaxPan)} },
+		func() Action { return SetTrackMute{Track: track(), Mute: rng.Intn(2) == 0} },
+		func() Action { return SetTrackSolo{Track: track(), Solo: rng.Intn(2) == 0} },
+		func() Action { return SetTrackName{Track: track(), Name: randomString(rng)} },
+		func() Action { return SetTrackSelected{Track: track(), Selected: rng.Intn(2) == 0} },
+		func() Action { return DeleteTrack{Track: track()} },
+		func() Action {
+			action := DeleteClip{Track: track()}
+			switch rng.Intn(3) {
+			case 0:
+				clip := rng.Intn(8)
+				action.Clip = &clip
+			case 1:
+				bar := 1 + rng.Intn(64)
+				action.Bar = &bar
+			default:
+				position := number(0, 100)
+				action.Position = &position
+			}
+			return action
+		},
+	}
+
+	actions := make([]Action, 1+rng.Intn(12))
+	for i := range actions {
+		actions[i] = generators[rng.Intn(len(generators))]()
+	}
+	return actions
+}
+
+// randomString returns a short string that exercises quoting and escapes
+func randomString(rng *rand.Rand) string {
+	alphabet := []rune("abcXYZ 019_-\"\\\n\t.()=,#é♪")
+	runes := make([]rune, rng.Intn(8))
+	for i := range runes {
+		runes[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+	return string(runes)
+}
@@ -0,0 +1,380 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// JSONSchemaDialect is the JSON Schema draft used by ActionSchema
+	JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+	// ActionSchemaID identifies the published action schema
+	ActionSchemaID = "https://github.com/Conceptual-Machines/magda-dsl/spec/actions.schema.json"
+)
+
+// Schema is the subset of JSON Schema (draft 2020-12) used to describe actions
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Const                interface{}        `json:"const,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+//go:generate go run ./internal/specgen -schema ../../spec/actions.schema.json
+
+// midiNoteDef is the $defs name of the MIDI note schema
+const midiNoteDef = "midi_note"
+
+// ActionSchema returns the JSON Schema for an action list, as produced by ParseDSL
+// It is generated from the action structs: json tags give property names and optionality,
+// and schema tags ("minimum=N", "maximum=N", "oneof") give constraints.
+func ActionSchema() *Schema {
+	defs := map[string]*Schema{
+		midiNoteDef: structSchema(reflect.TypeOf(MidiNote{})),
+	}
+	defs[midiNoteDef].Description = "A MIDI note; start and duration are in beats from the clip start"
+
+	var branches []*Schema
+	for _, proto := range actionPrototypes {
+		def := structSchema(reflect.TypeOf(proto))
+		def.Properties["action"] = &Schema{Const: proto.Type()}
+		def.Required = append([]string{"action"}, def.Required...)
+		defs[proto.Type()] = def
+		branches = append(branches, &Schema{Ref: "#/$defs/" + proto.Type()})
+	}
+	defs["action"] = &Schema{OneOf: branches}
+
+	return &Schema{
+		Schema:      JSONSchemaDialect,
+		ID:          ActionSchemaID,
+		Title:       "MAGDA DSL actions",
+		Description: "A list of DAW actions produced by the MAGDA DSL parser",
+		Type:        "array",
+		Items:       &Schema{Ref: "#/$defs/action"},
+		Defs:        defs,
+	}
+}
+
+// ActionSchemaJSON returns ActionSchema as indented JSON
+func ActionSchemaJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(ActionSchema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// structSchema builds an object schema from a struct's json and schema tags
+func structSchema(t reflect.Type) *Schema {
+	closed := false
+	s := &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: &closed,
+	}
+
+	var oneOf []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		prop := typeSchema(field.Type)
+		for _, constraint := range strings.Split(field.Tag.Get("schema"), ",") {
+			key, value, _ := strings.Cut(constraint, "=")
+			switch key {
+			case "minimum":
+				prop.Minimum = parseBound(value)
+			case "maximum":
+				prop.Maximum = parseBound(value)
+			case "oneof":
+				oneOf = append(oneOf, name)
+			}
+		}
+		s.Properties[name] = prop
+
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	// Fields tagged oneof form a group of which exactly one must be present
+	for _, name := range oneOf {
+		s.OneOf = append(s.OneOf, &Schema{Required: []string{name}})
+	}
+	return s
+}
+
+// typeSchema maps a Go field type to a JSON Schema type
+func typeSchema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Slice:
+		if t.Elem() == reflect.TypeOf(MidiNote{}) {
+			return &Schema{Type: "array", Items: &Schema{Ref: "#/$defs/" + midiNoteDef}}
+		}
+		return &Schema{Type: "array", Items: typeSchema(t.Elem())}
+	}
+	panic(fmt.Sprintf("dsl: no JSON Schema type for %s", t))
+}
+
+func parseBound(value string) *float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		panic(fmt.Sprintf("dsl: invalid schema bound %q", value))
+	}
+	return &f
+}
+
+// SchemaError is a validation failure at a location in a JSON document
+type SchemaError struct {
+	Path string // Location of the failing value, e.g. "[2].volume_db"
+	Msg  string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Path == "" {
+		return e.Msg
+	}
+	return e.Path + ": " + e.Msg
+}
+
+// ValidateActions checks a JSON action list against ActionSchema
+// It returns a *SchemaError describing the first violation, or nil if the list is valid.
+func ValidateActions(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return &SchemaError{Msg: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	schema := ActionSchema()
+	return schema.validate(schema, doc, "")
+}
+
+// validate checks value against s, resolving $refs against root
+//
+//nolint:gocyclo // One branch per supported JSON Schema keyword
+func (s *Schema) validate(root *Schema, value interface{}, path string) error {
+	if s.Ref != "" {
+		return root.resolve(s.Ref).validate(root, value, path)
+	}
+
+	if s.Type != "" && !hasJSONType(value, s.Type) {
+		return &SchemaError{Path: path, Msg: fmt.Sprintf("expected %s, got %s", s.Type, jsonTypeName(value))}
+	}
+	if s.Const != nil && fmt.Sprint(value) != fmt.Sprint(s.Const) {
+		return &SchemaError{Path: path, Msg: fmt.Sprintf("expected %v, got %v", s.Const, value)}
+	}
+
+	if n, ok := value.(json.Number); ok {
+		f, _ := n.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			return &SchemaError{Path: path, Msg: fmt.Sprintf("%v is less than minimum %v", n, *s.Minimum)}
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return &SchemaError{Path: path, Msg: fmt.Sprintf("%v is greater than maximum %v", n, *s.Maximum)}
+		}
+	}
+
+	if items, ok := value.([]interface{}); ok && s.Items != nil {
+		for i, item := range items {
+			if err := s.Items.validate(root, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if obj, ok := value.(map[string]interface{}); ok {
+		for _, name := range s.Required {
+			if _, present := obj[name]; !present {
+				return &SchemaError{Path: path, Msg: fmt.Sprintf("missing required property %q", name)}
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			prop, known := s.Properties[key]
+			if !known {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return &SchemaError{Path: path, Msg: fmt.Sprintf("unknown property %q", key)}
+				}
+				continue
+			}
+			if err := prop.validate(root, obj[key], joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(s.OneOf) > 0 {
+		return s.validateOneOf(root, value, path)
+	}
+	return nil
+}
+
+// validateOneOf checks that exactly one branch of s.OneOf matches
+// When no branch matches, the error comes from the branch whose const properties
+// (such as "action") match the value, so a bad create_track reports its own problem.
+func (s *Schema) validateOneOf(root *Schema, value interface{}, path string) error {
+	matches := 0
+	var discriminated error
+	for _, branch := range s.OneOf {
+		err := branch.validate(root, value, path)
+		if err == nil {
+			matches++
+			continue
+		}
+		if discriminated == nil && root.constsMatch(branch, value) {
+			discriminated = err
+		}
+	}
+	if matches == 1 {
+		return nil
+	}
+	if discriminated != nil {
+		return discriminated
+	}
+
+	// A oneOf of bare required lists is an "exactly one of these properties" group
+	if group := requiredGroup(s.OneOf); group != nil {
+		return &SchemaError{Path: path, Msg: fmt.Sprintf("expected exactly one of %s", strings.Join(group, ", "))}
+	}
+	if matches > 1 {
+		return &SchemaError{Path: path, Msg: fmt.Sprintf("matches %d alternatives, expected exactly one", matches)}
+	}
+
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return &SchemaError{Path: path, Msg: fmt.Sprintf("expected object, got %s", jsonTypeName(value))}
+	}
+	if action, ok := obj["action"]; ok {
+		return &SchemaError{Path: path, Msg: fmt.Sprintf("unknown action %v", action)}
+	}
+	return &SchemaError{Path: path, Msg: `missing required property "action"`}
+}
+
+// requiredGroup returns the property names of a oneOf made only of single required lists
+func requiredGroup(branches []*Schema) []string {
+	var names []string
+	for _, branch := range branches {
+		if branch.Ref != "" || branch.Type != "" || len(branch.Required) != 1 {
+			return nil
+		}
+		names = append(names, branch.Required[0])
+	}
+	return names
+}
+
+// constsMatch reports whether every const property of branch is present with the same value
+// Branches without const properties never match.
+func (s *Schema) constsMatch(branch *Schema, value interface{}) bool {
+	if branch.Ref != "" {
+		branch = s.resolve(branch.Ref)
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	found := false
+	for name, prop := range branch.Properties {
+		if prop.Const == nil {
+			continue
+		}
+		found = true
+		if fmt.Sprint(obj[name]) != fmt.Sprint(prop.Const) {
+			return false
+		}
+	}
+	return found
+}
+
+// resolve returns the schema for a local "#/$defs/name" reference
+func (s *Schema) resolve(ref string) *Schema {
+	name := strings.TrimPrefix(ref, "#/$defs/")
+	def, ok := s.Defs[name]
+	if !ok {
+		panic(fmt.Sprintf("dsl: unresolved schema reference %q", ref))
+	}
+	return def
+}
+
+// hasJSONType reports whether a decoded JSON value has the given JSON Schema type
+func hasJSONType(value interface{}, typ string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return typ == "object"
+	case []interface{}:
+		return typ == "array"
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case json.Number:
+		if typ == "number" {
+			return true
+		}
+		f, err := v.Float64()
+		return typ == "integer" && err == nil && f == math.Trunc(f)
+	case nil:
+		return typ == "null"
+	}
+	return false
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
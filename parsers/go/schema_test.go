@@ -0,0 +1,111 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateActions_parserOutput(t *testing.T) {
+	dslCode := `track(instrument="Serum", name="Bass").newClip(bar=1, length_bars=8).addMidi(notes=[{pitch=36, velocity=100, start=0, duration=1, channel=9}])
+track(id=1).newClip(start=2, length=1.5).addFX(fxname="ReaEQ").setVolume(volume_db=-3).setPan(pan=0.5)
+track(id=1).setMute(mute=true).setSolo(solo=false).setName(name="Lead").setSelected(selected=true)
+track(id=1).deleteClip(bar=2).deleteClip(position=4.5).deleteClip(clip=0).delete()
+track()`
+
+	actions, err := NewParser().ParseDSL(dslCode)
+	if err != nil {
+		t.Fatalf("ParseDSL() error = %v", err)
+	}
+	data, err := json.Marshal(actions)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if err := ValidateActions(data); err != nil {
+		t.Errorf("ValidateActions(ParseDSL output) error = %v\n%s", err, data)
+	}
+}
+
+func TestValidateActions_prototypes(t *testing.T) {
+	// Every action type must be covered by the schema
+	for _, proto := range actionPrototypes {
+		action := proto
+		if proto.Type() == ActionDeleteClip {
+			bar := 1
+			action = DeleteClip{Bar: &bar}
+		}
+		if proto.Type() == ActionCreateClipAtBar {
+			action = CreateClipAtBar{Bar: 1, LengthBars: 1}
+		}
+		data, err := json.Marshal([]Action{action})
+		if err != nil {
+			t.Fatalf("json.Marshal() error = %v", err)
+		}
+		if err := ValidateActions(data); err != nil {
+			t.Errorf("ValidateActions(%s) error = %v", data, err)
+		}
+	}
+}
+
+func TestValidateActions_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"not a list", `{"action":"delete_track","track":0}`, "expected array, got object"},
+		{"invalid JSON", `[{"action":`, "invalid JSON"},
+		{"not an object", `[1]`, "[0]: expected object, got number"},
+		{"missing action", `[{"track":0}]`, `[0]: missing required property "action"`},
+		{"unknown action", `[{"action":"explode","track":0}]`, "[0]: unknown action explode"},
+		{"missing field", `[{"action":"set_track_volume","track":0}]`, `[0]: missing required property "volume_db"`},
+		{"extra field", `[{"action":"delete_track","track":0,"force":true}]`, `[0]: unknown property "force"`},
+		{"wrong type", `[{"action":"set_track_mute","track":0,"mute":"yes"}]`, "[0].mute: expected boolean, got string"},
+		{"fractional integer", `[{"action":"create_track","index":1.5}]`, "[0].index: expected integer, got number"},
+		{"below minimum", `[{"action":"delete_track","track":-1}]`, "[0].track: -1 is less than minimum 0"},
+		{"above maximum", `[{"action":"delete_track","track":0},{"action":"set_track_volume","track":0,"volume_db":30}]`, "[1].volume_db: 30 is greater than maximum 24"},
+		{"bad note", `[{"action":"add_midi","track":0,"notes":[{"pitch":128,"velocity":100,"start":0,"duration":1}]}]`, "[0].notes[0].pitch: 128 is greater than maximum 127"},
+		{"delete_clip without target", `[{"action":"delete_clip","track":0}]`, "[0]: expected exactly one of clip, bar, position"},
+		{"delete_clip with two targets", `[{"action":"delete_clip","track":0,"bar":1,"clip":0}]`, "[0]: expected exactly one of clip, bar, position"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateActions([]byte(tt.json))
+			if err == nil {
+				t.Fatalf("ValidateActions(%s) = nil, want error containing %q", tt.json, tt.want)
+			}
+			var serr *SchemaError
+			if !errors.As(err, &serr) {
+				t.Fatalf("ValidateActions() error type = %T, want *SchemaError", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ValidateActions() error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateActions_integralNumber(t *testing.T) {
+	// 1.0 is an integer in JSON Schema
+	if err := ValidateActions([]byte(`[{"action":"create_track","index":1.0}]`)); err != nil {
+		t.Errorf("ValidateActions() error = %v", err)
+	}
+}
+
+func TestActionSchema_publishedFileIsCurrent(t *testing.T) {
+	want, err := ActionSchemaJSON()
+	if err != nil {
+		t.Fatalf("ActionSchemaJSON() error = %v", err)
+	}
+	got, err := os.ReadFile("../../spec/actions.schema.json")
+	if err != nil {
+		t.Fatalf("reading published schema: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("spec/actions.schema.json is out of date, run go generate ./...")
+	}
+}
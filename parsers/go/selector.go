@@ -0,0 +1,222 @@
+package dsl
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// selectorParams is the schema for keyword filters of tracks(...)
+// String filters compare with = ignoring case, or match a pattern with ~.
+var selectorParams = []paramDef{
+	stringParam("name"),
+	stringParam("instrument"),
+	stringParam("has_fx"),
+	boolParam("muted"),
+	boolParam("soloed"),
+	boolParam("selected"),
+}
+
+// SelectorParams returns the keyword filters accepted by tracks(...)
+func SelectorParams() []ParamSpec {
+	return paramSpecs(selectorParams)
+}
+
+// validateSelectorArgs checks the arguments of a tracks(...) selector
+// Positional arguments are track numbers, ranges such as 1..4, or all. Keyword arguments
+// are filters; ~ is only allowed on string filters and its pattern must compile.
+func validateSelectorArgs(call *TrackCall) error {
+	if len(call.Args) == 0 {
+		return trackError(call, "tracks() needs a selector, e.g. tracks(all) or tracks(1..4)")
+	}
+
+	var filters []*Arg
+	for _, arg := range call.Args {
+		if arg.Name != "" {
+			filters = append(filters, arg)
+			continue
+		}
+		if err := checkTrackSet(arg); err != nil {
+			return err
+		}
+	}
+	if err := validateArgs(tracksKeyword, call.Pos, filters, selectorParams); err != nil {
+		return err
+	}
+	for _, arg := range filters {
+		if !arg.Match {
+			continue
+		}
+		if def, _ := findParam(selectorParams, arg.Name); def.typ != ParamString {
+			return argError(tracksKeyword, arg, "%s cannot be matched with ~, use %s=", arg.Name, arg.Name)
+		}
+		if _, err := compilePattern(arg.Value.Text); err != nil {
+			return argError(tracksKeyword, arg, "%s: invalid pattern %q: %v", arg.Name, arg.Value.Text, err)
+		}
+	}
+	return nil
+}
+
+// checkTrackSet validates a positional selector argument: N, N..M or all
+func checkTrackSet(arg *Arg) error {
+	value := arg.Value
+	switch value.Kind {
+	case KeywordLiteral:
+		return nil
+	case NumberLiteral:
+		if n, ok := value.Int(); ok && n >= 1 {
+			return nil
+		}
+		return argError(tracksKeyword, arg, "track number must be a positive integer, got %s", value.describe())
+	case RangeLiteral:
+		from, fromOK := value.Elems[0].Int()
+		to, toOK := value.Elems[1].Int()
+		switch {
+		case !fromOK || !toOK || from < 1:
+			return argError(tracksKeyword, arg, "range bounds must be positive integers, got %s", value.Text)
+		case from > to:
+			return argError(tracksKeyword, arg, "empty range %s, the first track must not come after the last", value.Text)
+		}
+		return nil
+	}
+	return argError(tracksKeyword, arg, "expected a track number, range, all or name=value, got %s", value.describe())
+}
+
+// resolveSelector returns the tracks a tracks(...) selector matches, in track order
+// Positional arguments select the union of their tracks; without them every track is a
+// candidate. Every filter must then hold. A selector that matches no track is an error.
+func (p *Parser) resolveSelector(call *TrackCall) ([]int, error) {
+	if p.state == nil {
+		return nil, trackError(call, "cannot resolve %s: no project state set", call)
+	}
+
+	candidates := make([]bool, len(p.tracks.Tracks))
+	var positional []*Arg
+	var filters []trackFilter
+	for _, arg := range call.Args {
+		if arg.Name == "" {
+			positional = append(positional, arg)
+			continue
+		}
+		filter, err := newTrackFilter(arg)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	for _, arg := range positional {
+		from, to := 1, len(p.tracks.Tracks)
+		switch arg.Value.Kind {
+		case NumberLiteral:
+			from, _ = arg.Value.Int()
+			to = from
+		case RangeLiteral:
+			from, _ = arg.Value.Elems[0].Int()
+			to, _ = arg.Value.Elems[1].Int()
+		}
+		if to > len(p.tracks.Tracks) {
+			return nil, argError(tracksKeyword, arg, "track %d does not exist, the project has %d tracks", to, len(p.tracks.Tracks))
+		}
+		for i := from - 1; i < to; i++ {
+			candidates[i] = true
+		}
+	}
+
+	var tracks []int
+	for i, track := range p.tracks.Tracks {
+		if len(positional) > 0 && !candidates[i] {
+			continue
+		}
+		if matchesFilters(track, filters) {
+			tracks = append(tracks, i)
+		}
+	}
+	if len(tracks) == 0 {
+		return nil, trackError(call, "%s matches no tracks", call)
+	}
+	return tracks, nil
+}
+
+// trackFilter is a keyword filter of a selector, with its pattern compiled once
+type trackFilter struct {
+	arg   *Arg
+	match func(string) bool // Matcher of a string filter; nil for boolean filters
+}
+
+// newTrackFilter prepares a keyword filter: equal ignoring case for =, a pattern match for ~
+func newTrackFilter(arg *Arg) (trackFilter, error) {
+	filter := trackFilter{arg: arg}
+	if arg.Value.Kind != StringLiteral {
+		return filter, nil
+	}
+	if !arg.Match {
+		want := strings.TrimSpace(arg.Value.Text)
+		filter.match = func(s string) bool { return strings.EqualFold(strings.TrimSpace(s), want) }
+		return filter, nil
+	}
+	match, err := compilePattern(arg.Value.Text)
+	if err != nil {
+		return filter, argError(tracksKeyword, arg, "%s: invalid pattern %q: %v", arg.Name, arg.Value.Text, err)
+	}
+	filter.match = match
+	return filter, nil
+}
+
+// matchesFilters reports whether a track satisfies every keyword filter of a selector
+func matchesFilters(track TrackState, filters []trackFilter) bool {
+	for _, filter := range filters {
+		var ok bool
+		switch filter.arg.Name {
+		case "name":
+			ok = filter.match(track.Name)
+		case "instrument":
+			ok = filter.match(track.Instrument)
+		case "has_fx":
+			ok = slices.ContainsFunc(track.FX, filter.match)
+		case "muted":
+			ok = matchBool(filter.arg, track.Mute)
+		case "soloed":
+			ok = matchBool(filter.arg, track.Solo)
+		case "selected":
+			ok = matchBool(filter.arg, track.Selected)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func matchBool(arg *Arg, b bool) bool {
+	want, _ := arg.Value.Bool()
+	return b == want
+}
+
+// compilePattern compiles a ~ pattern into a matcher; patterns ignore case
+// A pattern wrapped in slashes, such as "/^(kick|snare)$/", is a regular expression that
+// may match anywhere in the name. Any other pattern is a glob matching the whole name,
+// where * matches any run of characters and ? a single one, as in "Drum*".
+func compilePattern(pattern string) (func(string) bool, error) {
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		expr := pattern[1 : len(pattern)-1]
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, err
+		}
+		return regexp.MustCompile("(?i)" + expr).MatchString, nil
+	}
+
+	var glob strings.Builder
+	glob.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			glob.WriteString(".*")
+		case '?':
+			glob.WriteString(".")
+		default:
+			glob.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	glob.WriteString("$")
+	return regexp.MustCompile(glob.String()).MatchString, nil
+}
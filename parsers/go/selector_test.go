@@ -0,0 +1,101 @@
+package dsl
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDSLParser_selectors(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{
+		{Name: "Drum Kick", FX: []string{"ReaEQ"}},
+		{Name: "Drum Snare", Mute: true},
+		{Name: "Bass", Instrument: "Serum", FX: []string{"ReaComp", "ReaEQ (Cockos)"}},
+		{Name: "Lead Synth", Instrument: "Serum", Selected: true},
+		{Name: "Pad Synth", Instrument: "Vital", Mute: true, Solo: true},
+	}}
+
+	tests := []struct {
+		name    string
+		dslCode string
+		want    []int // Tracks of the emitted set_track_mute actions
+		wantErr string
+	}{
+		{"all", `tracks(all).set_mute(mute=true)`, []int{0, 1, 2, 3, 4}, ""},
+		{"range", `tracks(2..4).set_mute(mute=true)`, []int{1, 2, 3}, ""},
+		{"numbers and ranges", `tracks(5, 1..2).set_mute(mute=true)`, []int{0, 1, 4}, ""},
+		{"glob", `tracks(name~"drum*").set_mute(mute=true)`, []int{0, 1}, ""},
+		{"glob matches the whole name", `tracks(name~"Synth").set_mute(mute=true)`, nil, `tracks(name~"Synth") matches no tracks`},
+		{"regex", `tracks(name~"/synth$/").set_mute(mute=true)`, []int{3, 4}, ""},
+		{"name ignores case", `tracks(name="bass").set_mute(mute=true)`, []int{2}, ""},
+		{"instrument", `tracks(instrument="serum").set_mute(mute=true)`, []int{2, 3}, ""},
+		{"has fx", `tracks(has_fx="ReaEQ").set_mute(mute=true)`, []int{0}, ""},
+		{"has fx pattern", `tracks(has_fx~"ReaEQ*").set_mute(mute=true)`, []int{0, 2}, ""},
+		{"muted", `tracks(muted=true).set_mute(mute=true)`, []int{1, 4}, ""},
+		{"soloed", `tracks(soloed=false, muted=true).set_mute(mute=true)`, []int{1}, ""},
+		{"selected", `tracks(selected=true).set_mute(mute=true)`, []int{3}, ""},
+		{"filters narrow numbers", `tracks(1..3, name~"Drum*", muted=false).set_mute(mute=true)`, []int{0}, ""},
+		{"no match", `tracks(name="Vocals").set_mute(mute=true)`, nil, `tracks(name="Vocals") matches no tracks`},
+		{"range past the end", `tracks(3..9).set_mute(mute=true)`, nil, "track 9 does not exist, the project has 5 tracks"},
+		{"empty range", `tracks(4..2).set_mute(mute=true)`, nil, "empty range 4..2, the first track must not come after the last"},
+		{"track zero", `tracks(0).set_mute(mute=true)`, nil, "track number must be a positive integer, got 0"},
+		{"no selector", `tracks().set_mute(mute=true)`, nil, "tracks() needs a selector, e.g. tracks(all) or tracks(1..4)"},
+		{"pattern on a boolean", `tracks(muted~true).set_mute(mute=true)`, nil, "muted cannot be matched with ~, use muted="},
+		{"bad regex", `tracks(name~"/(/").set_mute(mute=true)`, nil, `name: invalid pattern "/(/": error parsing regexp: missing closing ): ` + "`(`"},
+		{"unknown filter", `tracks(color="red").set_mute(mute=true)`, nil, `unknown parameter "color", expected one of name, instrument, has_fx, muted, soloed, selected`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			parser.SetState(state)
+			actions, err := parser.ParseProgram(tt.dslCode)
+			if tt.wantErr != "" {
+				var perr *ParseError
+				if !errors.As(err, &perr) || perr.Msg != tt.wantErr {
+					t.Errorf("ParseProgram() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseProgram() error = %v", err)
+			}
+			var got []int
+			for _, action := range actions {
+				got = append(got, action.(SetTrackMute).Track)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseProgram() tracks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSLParser_selectorWithoutState(t *testing.T) {
+	_, err := NewParser().ParseDSL(`tracks(all).set_mute(mute=true)`)
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Msg != "cannot resolve tracks(all): no project state set" || perr.Method != "tracks" {
+		t.Errorf("ParseDSL() error = %v, want missing state", err)
+	}
+}
+
+func TestDSLParser_selectorChains(t *testing.T) {
+	parser := NewParser()
+	parser.SetState(&ProjectState{Tracks: []TrackState{{Name: "Lead Synth"}, {Name: "Bass"}, {Name: "Pad Synth"}}})
+	got, err := parser.ParseDSL(`tracks(name~"*synth").set_volume(volume_db=-6).add_fx(fxname="ReaComp")
+tracks(name~"*synth").delete()`)
+	if err != nil {
+		t.Fatalf("ParseDSL() error = %v", err)
+	}
+	want := []map[string]interface{}{
+		{"action": "set_track_volume", "track": 0, "volume_db": -6.0},
+		{"action": "add_track_fx", "track": 0, "fxname": "ReaComp"},
+		{"action": "set_track_volume", "track": 2, "volume_db": -6.0},
+		{"action": "add_track_fx", "track": 2, "fxname": "ReaComp"},
+		{"action": "delete_track", "track": 2},
+		{"action": "delete_track", "track": 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDSL() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,272 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// ProjectState is the DAW project the parser resolves track references against
+// It encodes to JSON in the unwrapped form {"tempo": 120, "tracks": [...]}; DecodeState also
+// accepts the form wrapped as {"state": {...}} that DAW integrations send.
+type ProjectState struct {
+	Tempo  float64      `json:"tempo,omitempty"` // Beats per minute; 0 if unknown
+	Tracks []TrackState `json:"tracks"`
+}
+
+// TrackState is one track of a ProjectState, in track order
+type TrackState struct {
+	Name       string      `json:"name,omitempty"`
+	Instrument string      `json:"instrument,omitempty"`
+	Selected   bool        `json:"selected,omitempty"`
+	Mute       bool        `json:"mute,omitempty"`
+	Solo       bool        `json:"solo,omitempty"`
+	VolumeDB   float64     `json:"volume_db,omitempty"`
+	Pan        float64     `json:"pan,omitempty"`
+	FX         []string    `json:"fx,omitempty"`
+	Clips      []ClipState `json:"clips,omitempty"`
+	Ref        string      `json:"ref,omitempty"` // Handle the track was created with; see Parser.SetTrackHandles
+}
+
+// ClipState is a clip on a track, placed either at a 1-based bar or at a time position
+// Bar is 0 for clips placed by position.
+type ClipState struct {
+	Bar        int        `json:"bar,omitempty"`
+	LengthBars int        `json:"length_bars,omitempty"`
+	Position   float64    `json:"position,omitempty"`
+	Length     float64    `json:"length,omitempty"`
+	Notes      []MidiNote `json:"notes,omitempty"`
+}
+
+// StateError is a malformed value at a location in a DAW state document
+type StateError struct {
+	Path string // Location of the malformed value, e.g. "tracks[1].selected"
+	Msg  string
+}
+
+func (e *StateError) Error() string {
+	if e.Path == "" {
+		return "invalid state: " + e.Msg
+	}
+	return "invalid state: " + e.Path + ": " + e.Msg
+}
+
+// DecodeState decodes a DAW state JSON document
+// Both {"state": {"tracks": [...]}} and {"tracks": [...]} are accepted. Unknown fields are
+// ignored, so DAW integrations can send more than the parser uses. Wrong types and values
+// outside their ranges are reported as a *StateError.
+func DecodeState(data []byte) (*ProjectState, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return nil, &StateError{Msg: "expected a JSON object"}
+	}
+
+	var state ProjectState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, stateDecodeError(err)
+	}
+	if err := state.Validate(); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// UnmarshalJSON decodes a state in either the wrapped or the unwrapped form
+func (s *ProjectState) UnmarshalJSON(data []byte) error {
+	var wrapper struct {
+		State json.RawMessage `json:"state"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err == nil && bytes.HasPrefix(wrapper.State, []byte("{")) {
+		data = wrapper.State
+	}
+
+	type plain ProjectState // Without the UnmarshalJSON method
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*s = ProjectState(decoded)
+	return nil
+}
+
+// stateDecodeError converts a json error into a StateError with a path such as tracks[1].name
+func stateDecodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &StateError{
+			Path: jsonFieldPath(typeErr.Field),
+			Msg:  fmt.Sprintf("expected %s, got %s", goTypeJSONName(typeErr.Type), strings.Replace(typeErr.Value, "bool", "boolean", 1)),
+		}
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &StateError{Msg: fmt.Sprintf("invalid JSON at offset %d: %v", syntaxErr.Offset, err)}
+	}
+	return &StateError{Msg: err.Error()}
+}
+
+// jsonFieldPath turns "tracks.1.clips.0.bar" into "tracks[1].clips[0].bar"
+func jsonFieldPath(field string) string {
+	var path string
+	for _, part := range strings.Split(field, ".") {
+		if part != "" && strings.Trim(part, "0123456789") == "" {
+			path += "[" + part + "]"
+			continue
+		}
+		path = joinPath(path, part)
+	}
+	return path
+}
+
+// goTypeJSONName names the JSON type a Go type decodes from
+func goTypeJSONName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Int:
+		return "integer"
+	case reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "array"
+	}
+	return "object"
+}
+
+// Validate checks that every value is within the range the DSL accepts
+func (s *ProjectState) Validate() error {
+	if s.Tempo < 0 || math.IsNaN(s.Tempo) {
+		return &StateError{Path: "tempo", Msg: fmt.Sprintf("tempo %v must not be negative", s.Tempo)}
+	}
+	for i, track := range s.Tracks {
+		path := fmt.Sprintf("tracks[%d]", i)
+		if track.VolumeDB < MinVolumeDB || track.VolumeDB > MaxVolumeDB {
+			return &StateError{Path: path + ".volume_db", Msg: fmt.Sprintf("volume_db %v out of range [%v, %v]", track.VolumeDB, MinVolumeDB, MaxVolumeDB)}
+		}
+		if track.Pan < MinPan || track.Pan > MaxPan {
+			return &StateError{Path: path + ".pan", Msg: fmt.Sprintf("pan %v out of range [%v, %v]", track.Pan, MinPan, MaxPan)}
+		}
+		for j, clip := range track.Clips {
+			clipPath := fmt.Sprintf("%s.clips[%d]", path, j)
+			switch {
+			case clip.Bar < 0:
+				return &StateError{Path: clipPath + ".bar", Msg: fmt.Sprintf("bar %d must not be negative", clip.Bar)}
+			case clip.LengthBars < 0:
+				return &StateError{Path: clipPath + ".length_bars", Msg: fmt.Sprintf("length_bars %d must not be negative", clip.LengthBars)}
+			case clip.Position < 0:
+				return &StateError{Path: clipPath + ".position", Msg: fmt.Sprintf("position %v must not be negative", clip.Position)}
+			case clip.Length < 0:
+				return &StateError{Path: clipPath + ".length", Msg: fmt.Sprintf("length %v must not be negative", clip.Length)}
+			}
+		}
+	}
+	return nil
+}
+
+// SelectedTracks returns the indices of the selected tracks, in track order
+func (s *ProjectState) SelectedTracks() []int {
+	if s == nil {
+		return nil
+	}
+	var selected []int
+	for i, track := range s.Tracks {
+		if track.Selected {
+			selected = append(selected, i)
+		}
+	}
+	return selected
+}
+
+// HasTrack reports whether a track exists at the 0-based index
+func (s *ProjectState) HasTrack(index int) bool {
+	return s != nil && index >= 0 && index < len(s.Tracks)
+}
+
+// TrackByRef returns the index of the track with the handle ref, or -1 if there is none
+func (s *ProjectState) TrackByRef(ref string) int {
+	if s == nil || ref == "" {
+		return -1
+	}
+	return slices.IndexFunc(s.Tracks, func(track TrackState) bool { return track.Ref == ref })
+}
+
+// MatchTracks returns the indices of the tracks whose name matches, in track order
+// Matching tries, in turn: the exact name ignoring case; the name ignoring case, spaces and
+// punctuation ("bass-1" matches "Bass 1"); track names containing it as a word ("bass" matches
+// "Sub Bass"); and names within roughly one typo per three characters. The first rule that
+// matches any track wins, so several results mean the name is ambiguous.
+func (s *ProjectState) MatchTracks(name string) []int {
+	if s == nil {
+		return nil
+	}
+	target := normalizeTrackName(name)
+	if target == "" {
+		return nil
+	}
+
+	rules := []func(track string) bool{
+		func(track string) bool { return strings.EqualFold(strings.TrimSpace(track), strings.TrimSpace(name)) },
+		func(track string) bool { return normalizeTrackName(track) == target },
+		func(track string) bool { return containsWord(track, name) },
+	}
+	for _, rule := range rules {
+		var matches []int
+		for i, track := range s.Tracks {
+			if rule(track.Name) {
+				matches = append(matches, i)
+			}
+		}
+		if len(matches) > 0 {
+			return matches
+		}
+	}
+
+	var matches []int
+	bestDist := len(target)/3 + 1
+	for i, track := range s.Tracks {
+		dist := editDistance(target, normalizeTrackName(track.Name))
+		switch {
+		case dist < bestDist:
+			matches, bestDist = []int{i}, dist
+		case dist == bestDist && len(matches) > 0:
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// normalizeTrackName lowercases name and keeps only its letters and digits
+func normalizeTrackName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// containsWord reports whether the words of name appear consecutively among the words of track
+func containsWord(track, name string) bool {
+	split := func(s string) []string {
+		return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	}
+	haystack, needle := split(track), split(name)
+	if len(needle) == 0 {
+		return false
+	}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if slices.Equal(haystack[i:i+len(needle)], needle) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package dsl
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeState(t *testing.T) {
+	want := &ProjectState{
+		Tempo: 128,
+		Tracks: []TrackState{
+			{Name: "Drums", Mute: true, FX: []string{"ReaComp"}},
+			{
+				Name:       "Bass",
+				Instrument: "Serum",
+				Selected:   true,
+				VolumeDB:   -6,
+				Pan:        -0.25,
+				Clips: []ClipState{
+					{Bar: 1, LengthBars: 4, Notes: []MidiNote{{Pitch: 36, Velocity: 100, Duration: 1}}},
+					{Position: 8.5, Length: 2},
+				},
+			},
+		},
+	}
+	unwrapped := `{
+		"tempo": 128,
+		"tracks": [
+			{"name": "Drums", "mute": true, "fx": ["ReaComp"], "color": "#ff0000"},
+			{"name": "Bass", "instrument": "Serum", "selected": true, "volume_db": -6, "pan": -0.25,
+			 "clips": [{"bar": 1, "length_bars": 4, "notes": [{"pitch": 36, "velocity": 100, "start": 0, "duration": 1}]},
+			           {"position": 8.5, "length": 2}]}
+		]
+	}`
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unwrapped", unwrapped},
+		{"wrapped", `{"state": ` + unwrapped + `}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeState([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("DecodeState() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("DecodeState() =\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeState_roundTrip(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{{Name: "Bass", Selected: true, Clips: []ClipState{{Bar: 2, LengthBars: 1}}}}}
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeState(data)
+	if err != nil {
+		t.Fatalf("DecodeState(%s) error = %v", data, err)
+	}
+	if !reflect.DeepEqual(got, state) {
+		t.Errorf("DecodeState(json.Marshal(s)) = %+v, want %+v", got, state)
+	}
+}
+
+func TestDecodeState_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", ``, "invalid state: expected a JSON object"},
+		{"array", `[{"name": "Bass"}]`, "invalid state: expected a JSON object"},
+		{"syntax", `{"tracks": [}`, "invalid state: invalid JSON at offset 13: invalid character '}' looking for beginning of value"},
+		{"tracks not an array", `{"tracks": {"name": "Bass"}}`, "invalid state: tracks: expected array, got object"},
+		{"wrong field type", `{"state": {"tracks": [{}, {"selected": "yes"}]}}`, "invalid state: tracks[1].selected: expected boolean, got string"},
+		{"fractional bar", `{"tracks": [{"clips": [{"bar": 1.5}]}]}`, "invalid state: tracks[0].clips[0].bar: expected integer, got number 1.5"},
+		{"negative tempo", `{"tempo": -1, "tracks": []}`, "invalid state: tempo: tempo -1 must not be negative"},
+		{"volume out of range", `{"tracks": [{"volume_db": 40}]}`, "invalid state: tracks[0].volume_db: volume_db 40 out of range [-150, 24]"},
+		{"pan out of range", `{"tracks": [{}, {"pan": -2}]}`, "invalid state: tracks[1].pan: pan -2 out of range [-1, 1]"},
+		{"negative clip position", `{"tracks": [{"clips": [{"position": -4}]}]}`, "invalid state: tracks[0].clips[0].position: position -4 must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeState([]byte(tt.data))
+			var serr *StateError
+			if !errors.As(err, &serr) {
+				t.Fatalf("DecodeState() error = %v, want *StateError", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("DecodeState() error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectState_SelectedTracks(t *testing.T) {
+	var none *ProjectState
+	if got := none.SelectedTracks(); got != nil {
+		t.Errorf("nil state SelectedTracks() = %v, want nil", got)
+	}
+	state := &ProjectState{Tracks: []TrackState{{Selected: true}, {}, {Selected: true}}}
+	if got := state.SelectedTracks(); !reflect.DeepEqual(got, []int{0, 2}) {
+		t.Errorf("SelectedTracks() = %v, want [0 2]", got)
+	}
+	if !state.HasTrack(2) || state.HasTrack(3) || state.HasTrack(-1) || none.HasTrack(0) {
+		t.Errorf("HasTrack() disagrees with the track list")
+	}
+}
+
+func TestProjectState_MatchTracks(t *testing.T) {
+	state := &ProjectState{Tracks: []TrackState{
+		{Name: "Bass"},
+		{Name: "Sub Bass"},
+		{Name: "bass-2"},
+		{Name: "Drum Bus"},
+	}}
+
+	tests := []struct {
+		name string
+		want []int
+	}{
+		{"Bass", []int{0}},
+		{" BASS ", []int{0}},
+		{"Bass 2", []int{2}},
+		{"sub", []int{1}},
+		{"drum", []int{3}},
+		{"Drum Buss", []int{3}},
+		{"Vocals", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := state.MatchTracks(tt.name); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchTracks(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	var empty *ProjectState
+	if got := empty.MatchTracks("Bass"); got != nil {
+		t.Errorf("nil MatchTracks() = %v, want nil", got)
+	}
+}